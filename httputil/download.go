@@ -0,0 +1,414 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+// ErrResourceChanged is returned when a resource changes during a download
+var ErrResourceChanged = errors.New("resource changed during download")
+
+// StatusError is returned when a server replies with an unexpected status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Downloader downloads resources with range requests, and resumes them
+// after failures.
+//
+// The first range request reveals the size of the resource and its
+// validators (ETag and Last-Modified). The following requests carry an
+// If-Range condition, and the download fails with ErrResourceChanged when
+// the resource is modified. Servers which do not support ranges are
+// downloaded in a single request.
+type Downloader struct {
+	// Client sends the requests (http.DefaultClient by default)
+	Client *http.Client
+	// Header is added to every request (optional)
+	Header http.Header
+	// ChunkSize is the size of each range request (8 MiB by default)
+	ChunkSize unit.Byte
+	// Parallel is the number of ranges downloaded concurrently (1 by default)
+	Parallel int
+	// MaxRetries is the number of consecutive failed attempts allowed for a
+	// range before giving up (5 by default). Attempts which make progress
+	// reset the count.
+	MaxRetries int
+	// Backoff is the delay before the first retry, which doubles on every
+	// attempt (1s by default). Retry-After headers sent with a 429 or 503
+	// take precedence.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries when the server does not
+	// send Retry-After (1m by default)
+	MaxBackoff time.Duration
+	// MaxRetryAfter caps the delays sent by servers with Retry-After (1m by
+	// default)
+	MaxRetryAfter time.Duration
+}
+
+// Download downloads the resource at url to w, and returns its size
+func (d *Downloader) Download(ctx context.Context, url string, w io.WriterAt) (int64, error) {
+	dl := &download{d: d, url: url, w: w, size: UnknownSize}
+	chunk := d.chunkSize()
+
+	// The first range reveals the size of the resource
+	pos, err := dl.fetch(ctx, 0, chunk-1)
+	if err != nil {
+		return 0, err
+	}
+	size := dl.size
+	switch {
+	case dl.full:
+		return pos, nil
+	case size == UnknownSize:
+		// Ranges cannot be planned, so the rest is downloaded sequentially
+		return dl.fetch(ctx, pos, -1)
+	case pos >= size:
+		return size, nil
+	}
+
+	// Download the remaining ranges with a pool of workers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ranges := make(chan HTTPRange)
+	go func() {
+		defer close(ranges)
+		for start := pos; start < size; start += chunk {
+			end := start + chunk - 1
+			if end >= size {
+				end = size - 1
+			}
+			select {
+			case ranges <- HTTPRange{Start: start, End: end, Size: size}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < d.parallel(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range ranges {
+				if _, err := dl.fetch(ctx, r.Start, r.End); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return http.DefaultClient
+}
+
+func (d *Downloader) chunkSize() int64 {
+	if d.ChunkSize >= 1 {
+		return int64(d.ChunkSize)
+	}
+	return int64(8 * unit.MiB)
+}
+
+func (d *Downloader) parallel() int {
+	if d.Parallel > 0 {
+		return d.Parallel
+	}
+	return 1
+}
+
+func (d *Downloader) maxRetries() int {
+	if d.MaxRetries > 0 {
+		return d.MaxRetries
+	}
+	return 5
+}
+
+func (d *Downloader) maxRetryAfter() time.Duration {
+	if d.MaxRetryAfter > 0 {
+		return d.MaxRetryAfter
+	}
+	return time.Minute
+}
+
+func (d *Downloader) backoff(attempt int) time.Duration {
+	b := d.Backoff
+	if b <= 0 {
+		b = time.Second
+	}
+	max := d.MaxBackoff
+	if max <= 0 {
+		max = time.Minute
+	}
+	for ; attempt > 0 && b < max; attempt-- {
+		b <<= 1
+	}
+	if b > max {
+		return max
+	}
+	return b
+}
+
+// download is the state shared by the requests of a download
+type download struct {
+	d   *Downloader
+	url string
+	w   io.WriterAt
+
+	mu           sync.Mutex
+	known        bool // whether the first response has been received
+	ranges       bool // whether the server supports ranges
+	full         bool // whether the complete resource has been received
+	size         int64
+	etag         string
+	lastModified string
+}
+
+// retryableError is a failure which can be retried, optionally after a delay
+type retryableError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// fetch downloads the bytes from start to end (inclusive) to the writer,
+// resuming after failures. An end of -1 downloads up to the end of the
+// resource. It returns the position following the last byte downloaded.
+func (dl *download) fetch(ctx context.Context, start, end int64) (int64, error) {
+	pos, attempt := start, 0
+	for {
+		next, done, err := dl.get(ctx, pos, end)
+		if next > pos {
+			pos, attempt = next, 0
+		}
+		if done {
+			return pos, nil
+		}
+		if err == nil {
+			continue
+		}
+
+		rerr, ok := err.(*retryableError)
+		if !ok {
+			return pos, err
+		}
+		if attempt >= dl.d.maxRetries() {
+			return pos, rerr.err
+		}
+		delay := rerr.delay
+		if delay <= 0 {
+			delay = dl.d.backoff(attempt)
+		}
+		attempt++
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return pos, ctx.Err()
+		}
+	}
+}
+
+// get sends a single range request and copies its body to the writer. It
+// returns the position following the last byte written, and whether the
+// range is complete.
+func (dl *download) get(ctx context.Context, pos, end int64) (int64, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, dl.url, nil)
+	if err != nil {
+		return pos, false, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range dl.d.Header {
+		req.Header[k] = v
+	}
+	ran := "bytes=" + strconv.FormatInt(pos, 10) + "-"
+	if end >= 0 {
+		ran += strconv.FormatInt(end, 10)
+	}
+	req.Header.Set("Range", ran)
+	if v := dl.validator(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+
+	res, err := dl.d.client().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return pos, false, ctx.Err()
+		}
+		return pos, false, &retryableError{err: err}
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return dl.copyPartial(pos, end, res)
+	case http.StatusOK:
+		return dl.copyFull(pos, res)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The position is past the end of the resource
+		r, err := ParseContentRange(res.Header.Get(contentRange))
+		if err != nil || r.Unit != "" || !r.Unsatisfied() || pos < r.Size {
+			return pos, false, &StatusError{StatusCode: res.StatusCode}
+		}
+		if err := dl.check(res, r.Size); err != nil {
+			return pos, false, err
+		}
+		return pos, true, nil
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		delay, _ := ParseRetryAfter(res.Header)
+		if max := dl.d.maxRetryAfter(); delay > max {
+			delay = max
+		}
+		return pos, false, &retryableError{err: &StatusError{StatusCode: res.StatusCode}, delay: delay}
+	}
+	if res.StatusCode >= 500 {
+		return pos, false, &retryableError{err: &StatusError{StatusCode: res.StatusCode}}
+	}
+	return pos, false, &StatusError{StatusCode: res.StatusCode}
+}
+
+// copyPartial copies the body of a 206 Partial Content response
+func (dl *download) copyPartial(pos, end int64, res *http.Response) (int64, bool, error) {
+	r, err := ParseContentRange(res.Header.Get(contentRange))
+	if err != nil {
+		return pos, false, fmt.Errorf("invalid Content-Range: %s", err)
+	}
+	if r.Unit != "" || r.Unsatisfied() || r.Start != pos {
+		return pos, false, fmt.Errorf("unexpected Content-Range %s", r)
+	}
+	dl.mu.Lock()
+	dl.ranges = true
+	dl.mu.Unlock()
+	if err := dl.check(res, r.Size); err != nil {
+		return pos, false, err
+	}
+
+	n, err := io.CopyN(&offsetWriter{w: dl.w, off: pos}, res.Body, r.Length())
+	pos += n
+	if err != nil {
+		return pos, false, &retryableError{err: err}
+	}
+	done := (r.Size != UnknownSize && pos >= r.Size) || (end >= 0 && pos > end)
+	return pos, done, nil
+}
+
+// copyFull copies the body of a 200 OK response, which contains the whole
+// resource
+func (dl *download) copyFull(pos int64, res *http.Response) (int64, bool, error) {
+	dl.mu.Lock()
+	ranges := dl.ranges
+	dl.mu.Unlock()
+	if ranges {
+		// The If-Range condition failed
+		return pos, false, ErrResourceChanged
+	}
+	if err := dl.check(res, res.ContentLength); err != nil {
+		return pos, false, err
+	}
+
+	// Ranges are not supported, so the bytes already written are skipped
+	if _, err := io.CopyN(ioutil.Discard, res.Body, pos); err != nil {
+		return pos, false, &retryableError{err: err}
+	}
+	n, err := io.Copy(&offsetWriter{w: dl.w, off: pos}, res.Body)
+	pos += n
+	if err != nil {
+		return pos, false, &retryableError{err: err}
+	}
+	if res.ContentLength >= 0 && pos != res.ContentLength {
+		return pos, false, &retryableError{err: io.ErrUnexpectedEOF}
+	}
+
+	dl.mu.Lock()
+	dl.full = true
+	dl.size = pos
+	dl.mu.Unlock()
+	return pos, true, nil
+}
+
+// check records the size and validators of the resource from the first
+// response, and ensures that the following responses match them
+func (dl *download) check(res *http.Response, size int64) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	etag := res.Header.Get("ETag")
+	lastModified := res.Header.Get("Last-Modified")
+	if !dl.known {
+		dl.known = true
+		dl.size = size
+		dl.etag = etag
+		dl.lastModified = lastModified
+		return nil
+	}
+	if (size != UnknownSize && dl.size != UnknownSize && size != dl.size) ||
+		(dl.etag != "" && etag != "" && etag != dl.etag) ||
+		(dl.lastModified != "" && lastModified != "" && lastModified != dl.lastModified) {
+		return ErrResourceChanged
+	}
+	return nil
+}
+
+// validator returns the If-Range value identifying the resource. Weak
+// entity tags cannot be used with If-Range.
+func (dl *download) validator() string {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	if dl.etag != "" && !strings.HasPrefix(dl.etag, "W/") {
+		return dl.etag
+	}
+	return dl.lastModified
+}
+
+// offsetWriter writes sequentially to an io.WriterAt
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
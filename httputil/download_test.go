@@ -0,0 +1,317 @@
+package httputil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/unit"
+)
+
+// TestDownloader ensures that resources are downloaded with range requests
+// and resumed after failures
+func TestDownloader(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 100)
+
+	table := []struct {
+		name     string
+		parallel int
+		handler  func(n int64, w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name: "sequential",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				serveString(w, r, data, `"v1"`)
+			},
+		},
+		{
+			name:     "parallel",
+			parallel: 4,
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				serveString(w, r, data, `"v1"`)
+			},
+		},
+		{
+			name:     "interrupted",
+			parallel: 3,
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				if n%2 == 1 {
+					serveString(&truncatedWriter{ResponseWriter: w, n: 100}, r, data, `"v1"`)
+					return
+				}
+				serveString(w, r, data, `"v1"`)
+			},
+		},
+		{
+			name: "unavailable",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				if n%3 == 0 {
+					httputil.FormatRetryAfter(w.Header(), 0)
+					http.Error(w, "slow down", http.StatusTooManyRequests)
+					return
+				}
+				if n%3 == 1 {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				serveString(w, r, data, `"v1"`)
+			},
+		},
+		{
+			name: "unknown size",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				ranges, err := httputil.ParseRange(r.Header.Get("Range"), int64(len(data)))
+				if err == httputil.ErrUnsatisfiableRange {
+					httputil.FormatContentRange(w.Header(), httputil.HTTPRange{Start: -1, End: -1, Size: int64(len(data))})
+					w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				ran := ranges[0]
+				ran.Size = httputil.UnknownSize
+				httputil.FormatContentRange(w.Header(), ran)
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(data[ran.Start : ran.End+1]))
+			},
+		},
+		{
+			name: "no range support",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+				if n == 0 {
+					w.Write([]byte(data[:500]))
+					panic(http.ErrAbortHandler)
+				}
+				w.Write([]byte(data))
+			},
+		},
+	}
+
+	for _, test := range table {
+		var requests int64
+		handler := test.handler
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(atomic.AddInt64(&requests, 1)-1, w, r)
+		}))
+
+		d := &httputil.Downloader{
+			Client:    srv.Client(),
+			ChunkSize: unit.Byte(256),
+			Parallel:  test.parallel,
+			Backoff:   time.Millisecond,
+		}
+		buf := &writerAt{}
+		size, err := d.Download(context.Background(), srv.URL, buf)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s - unexpected error %s", test.name, err)
+			continue
+		}
+		if size != int64(len(data)) {
+			t.Errorf("%s - expect size %d, but got %d", test.name, len(data), size)
+		}
+		if buf.String() != data {
+			t.Errorf("%s - expect the downloaded data to match the resource", test.name)
+		}
+	}
+}
+
+// TestDownloader_Errors ensures that downloads fail when they cannot be
+// completed
+func TestDownloader_Errors(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Repeat("0123456789", 100)
+
+	table := []struct {
+		name    string
+		handler func(n int64, w http.ResponseWriter, r *http.Request)
+		check   func(err error) bool
+	}{
+		{
+			name: "changed etag",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				etag := `"v1"`
+				if n > 1 {
+					etag = `"v2"`
+				}
+				serveString(w, r, data, etag)
+			},
+			check: func(err error) bool { return err == httputil.ErrResourceChanged },
+		},
+		{
+			name: "changed last modified",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				modified := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+				if n > 1 {
+					modified = modified.Add(time.Hour)
+				}
+				w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+				serveString(w, r, data, "")
+			},
+			check: func(err error) bool { return err == httputil.ErrResourceChanged },
+		},
+		{
+			name: "not found",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			check: func(err error) bool {
+				serr, ok := err.(*httputil.StatusError)
+				return ok && serr.StatusCode == http.StatusNotFound
+			},
+		},
+		{
+			name: "too many failures",
+			handler: func(n int64, w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "oops", http.StatusBadGateway)
+			},
+			check: func(err error) bool {
+				serr, ok := err.(*httputil.StatusError)
+				return ok && serr.StatusCode == http.StatusBadGateway
+			},
+		},
+	}
+
+	for _, test := range table {
+		var requests int64
+		handler := test.handler
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(atomic.AddInt64(&requests, 1)-1, w, r)
+		}))
+
+		d := &httputil.Downloader{
+			Client:     srv.Client(),
+			ChunkSize:  unit.Byte(256),
+			MaxRetries: 2,
+			Backoff:    time.Millisecond,
+		}
+		_, err := d.Download(context.Background(), srv.URL, &writerAt{})
+		srv.Close()
+		if !test.check(err) {
+			t.Errorf("%s - unexpected error %v", test.name, err)
+		}
+	}
+}
+
+// TestDownloader_MaxRetryAfter ensures that the delays sent by servers are
+// capped
+func TestDownloader_MaxRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Repeat("0123456789", 100)
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "slow down", http.StatusServiceUnavailable)
+			return
+		}
+		serveString(w, r, data, `"v1"`)
+	}))
+	defer srv.Close()
+
+	d := &httputil.Downloader{
+		Client:        srv.Client(),
+		ChunkSize:     unit.Byte(256),
+		MaxRetryAfter: time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	buf := &writerAt{}
+	if _, err := d.Download(ctx, srv.URL, buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != data {
+		t.Errorf("expect the downloaded data to match the resource")
+	}
+}
+
+// TestDownloader_MaxBackoff ensures that the exponential backoff is capped
+func TestDownloader_MaxBackoff(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Repeat("0123456789", 100)
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) <= 5 {
+			http.Error(w, "oops", http.StatusInternalServerError)
+			return
+		}
+		serveString(w, r, data, `"v1"`)
+	}))
+	defer srv.Close()
+
+	// Uncapped, 5 retries would wait 100ms+200ms+...+1.6s
+	d := &httputil.Downloader{
+		Client:     srv.Client(),
+		ChunkSize:  unit.Byte(2048),
+		MaxRetries: 5,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 100 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	buf := &writerAt{}
+	if _, err := d.Download(ctx, srv.URL, buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != data {
+		t.Errorf("expect the downloaded data to match the resource")
+	}
+}
+
+// serveString serves data with range support
+func serveString(w http.ResponseWriter, r *http.Request, data, etag string) {
+	httputil.ServeRange(w, r, &httputil.Content{
+		Reader: strings.NewReader(data),
+		Size:   int64(len(data)),
+		ETag:   etag,
+	})
+}
+
+// truncatedWriter aborts the response after n bytes of body
+type truncatedWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *truncatedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		w.ResponseWriter.Write(p[:w.n])
+		panic(http.ErrAbortHandler)
+	}
+	w.n -= len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// writerAt is an in-memory io.WriterAt
+type writerAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func (w *writerAt) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return string(w.buf)
+}
@@ -0,0 +1,208 @@
+package httputil
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/deixis/errors"
+)
+
+// EncodeQuery encodes the values of v into an HTTP query. It is the inverse
+// of ParseQuery, so the query can be parsed back into the same value.
+//
+// Empty values are omitted (e.g. nil pointers, zero values, empty slices),
+// unless the field has a default option. Types implementing
+// encoding.TextMarshaler are encoded with MarshalText. Nested structs are
+// encoded with dotted keys (e.g. filter.status=x), and maps with bracketed
+// keys (e.g. labels[env]=prod).
+func EncodeQuery(v interface{}) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("httputil: EncodeQuery(unsupported type " + reflect.TypeOf(v).String() + ")")
+	}
+
+	q := url.Values{}
+	if err := encodeStruct(q, rv, ""); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// encodeStruct encodes the fields of rv with keys starting with prefix
+func encodeStruct(q url.Values, rv reflect.Value, prefix string) error {
+	tv := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		field := tv.Field(i)
+		val := rv.Field(i)
+
+		tag, ok := field.Tag.Lookup(queryStringTag)
+		if !ok {
+			// Embedded structs share the prefix of their parent
+			if field.Anonymous && isStructType(field.Type) {
+				if val, ok := deref(val); ok {
+					if err := encodeStruct(q, val, prefix); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		tag, opts := parseTag(tag)
+		if prefix != "" {
+			tag = prefix + "." + tag
+		}
+
+		// Pointers are omitted when nil, but not when they point to a zero value
+		isPtr := val.Kind() == reflect.Ptr
+		val, ok = deref(val)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case isSliceType(field.Type):
+			if err := encodeSlice(q, tag, opts, val); err != nil {
+				return err
+			}
+		case isMapType(field.Type):
+			if err := encodeMap(q, tag, val); err != nil {
+				return err
+			}
+		case isStructType(field.Type):
+			if err := encodeStruct(q, val, tag); err != nil {
+				return err
+			}
+		default:
+			if !isPtr && isZero(val) {
+				if _, ok := opts.Get("default"); !ok {
+					continue
+				}
+			}
+			s, err := formatValue(val)
+			if err != nil {
+				return errors.Wrap(err, "httputil: cannot encode "+tag)
+			}
+			q.Set(tag, s)
+		}
+	}
+	return nil
+}
+
+// encodeSlice encodes the items of a slice, either with repeated keys or
+// joined with the delimiter set in the tag options. Empty items are omitted,
+// since ParseQuery ignores them.
+func encodeSlice(q url.Values, tag string, opts tagOptions, val reflect.Value) error {
+	sep := opts.delimiter()
+	var items []string
+	for i := 0; i < val.Len(); i++ {
+		item, ok := deref(val.Index(i))
+		if !ok {
+			continue
+		}
+		s, err := formatValue(item)
+		if err != nil {
+			return errors.Wrap(err, "httputil: cannot encode "+tag)
+		}
+		if s == "" {
+			continue
+		}
+		if sep != "" && strings.Contains(s, sep) {
+			return errors.New("httputil: cannot encode " + tag + ": " + strconv.Quote(s) + " contains the delimiter")
+		}
+		items = append(items, s)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	if sep == "" {
+		q[tag] = items
+		return nil
+	}
+	q.Set(tag, strings.Join(items, sep))
+	return nil
+}
+
+// encodeMap encodes the entries of a map with bracketed keys
+func encodeMap(q url.Values, tag string, val reflect.Value) error {
+	iter := val.MapRange()
+	for iter.Next() {
+		elem, ok := deref(iter.Value())
+		if !ok {
+			continue
+		}
+		s, err := formatValue(elem)
+		if err != nil {
+			return errors.Wrap(err, "httputil: cannot encode "+tag)
+		}
+		if s == "" {
+			continue
+		}
+		q.Set(tag+"["+iter.Key().String()+"]", s)
+	}
+	return nil
+}
+
+// formatValue formats a value which is not a pointer
+func formatValue(val reflect.Value) (string, error) {
+	if m, ok := textMarshaler(val); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'g', -1, val.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool()), nil
+	case reflect.String:
+		return val.String(), nil
+	}
+	return "", errors.New("unsupported type " + val.Type().String())
+}
+
+// textMarshaler returns the encoding.TextMarshaler of val, if any
+func textMarshaler(val reflect.Value) (encoding.TextMarshaler, bool) {
+	if val.CanInterface() {
+		if m, ok := val.Interface().(encoding.TextMarshaler); ok {
+			return m, true
+		}
+	}
+	if val.CanAddr() && val.Addr().CanInterface() {
+		if m, ok := val.Addr().Interface().(encoding.TextMarshaler); ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// deref walks down pointers and interfaces, and returns false when it gets
+// to a nil value
+func deref(val reflect.Value) (reflect.Value, bool) {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return val, false
+		}
+		val = val.Elem()
+	}
+	return val, true
+}
@@ -0,0 +1,162 @@
+package httputil_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/lang"
+	"github.com/deixis/pkg/unit"
+	"github.com/deixis/pkg/utc"
+)
+
+func TestEncodeQuery(t *testing.T) {
+	t.Parallel()
+
+	min := parseUTC(t, "2027-12-20T14:00:00Z")
+	q := ""
+	size := 3 * unit.MB
+
+	table := []struct {
+		input  interface{}
+		expect string
+	}{
+		{input: &dummyParseQuery{}, expect: ""},
+		{input: (*dummyParseQuery)(nil), expect: ""},
+		{
+			input:  dummyParseQuery{Min: min, Limit: 15, Q: &q, B: true, F: 0.5},
+			expect: "b=true&f=0.5&limit=15&min=2027-12-20T14%3A00%3A00Z&q=",
+		},
+		{
+			input:  &dummyParseQueryUnit{TTL: 7 * unit.Day, Size: &size},
+			expect: "size=3+MB&ttl=1w",
+		},
+		{
+			input:  &dummyParseQuerySlice{IDs: []int64{1, 2}, Names: []string{"a", "", "b"}, Tags: []string{"x", "y"}},
+			expect: "id=1&id=2&names=a%2Cb&tags=x+y",
+		},
+		{
+			input: &dummyParseQueryNested{
+				dummyPagination: dummyPagination{Limit: 10},
+				Filter:          dummyFilter{Status: "open", Tags: []string{"a", "b"}},
+				Labels:          map[string]string{"env": "prod", "team": ""},
+			},
+			expect: "filter.status=open&filter.tags=a%2Cb&labels%5Benv%5D=prod&limit=10",
+		},
+		{
+			input:  &dummyParseQueryRules{},
+			expect: "limit=0&sort=",
+		},
+	}
+
+	for i, test := range table {
+		res, err := httputil.EncodeQuery(test.input)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		if got := res.Encode(); test.expect != got {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, got)
+		}
+	}
+}
+
+func TestEncodeQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	table := []interface{}{
+		"foo",
+		&dummyParseQuerySlice{Names: []string{"a,b"}},
+		&struct {
+			C chan int `qs:"c"`
+		}{C: make(chan int)},
+	}
+	for i, v := range table {
+		if _, err := httputil.EncodeQuery(v); err == nil {
+			t.Errorf("#%d - expect an error", i)
+		}
+	}
+}
+
+type dummyRoundTrip struct {
+	dummyPagination
+	Min    utc.UTC            `qs:"min"`
+	Max    *utc.UTC           `qs:"max"`
+	Lang   lang.Tag           `qs:"lang"`
+	Langs  []lang.Tag         `qs:"langs,comma"`
+	Size   unit.Byte          `qs:"size"`
+	TTL    *unit.Duration     `qs:"ttl"`
+	Ratio  float32            `qs:"ratio"`
+	Zero   *int               `qs:"zero"`
+	Small  int8               `qs:"small"`
+	IDs    []uint             `qs:"id"`
+	Sort   string             `qs:"sort,default=asc,enum=asc|desc"`
+	Limit  int                `qs:"max_items,default=20"`
+	Filter *dummyFilter       `qs:"filter"`
+	Labels map[string]string  `qs:"labels"`
+	Dates  map[string]utc.UTC `qs:"dates"`
+}
+
+// TestEncodeQueryRoundTrip ensures that encoded queries are parsed back to
+// the same value
+func TestEncodeQueryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	max := parseUTC(t, "2028-01-01T00:00:00.5Z")
+	ttl := 2*unit.Week + unit.Duration(90*time.Minute)
+	zero := 0
+
+	table := []dummyRoundTrip{
+		{Sort: "asc", Limit: 20},
+		{Sort: "desc", Limit: 0},
+		{
+			dummyPagination: dummyPagination{Limit: 5, Continuation: "g2gCbQ=="},
+			Min:             parseUTC(t, "2027-12-20T14:00:00Z"),
+			Max:             &max,
+			Lang:            lang.SwissFrench,
+			Langs:           []lang.Tag{lang.English, lang.SwissGerman},
+			Size:            unit.Byte(1536),
+			TTL:             &ttl,
+			Ratio:           0.1,
+			Zero:            &zero,
+			Small:           -8,
+			IDs:             []uint{3, 1, 2},
+			Sort:            "asc",
+			Limit:           100,
+			Filter: &dummyFilter{
+				Status: "open & closed",
+				Tags:   []string{"a b", "c"},
+				Range: &struct {
+					Min int `qs:"min"`
+					Max int `qs:"max"`
+				}{Max: 3},
+			},
+			Labels: map[string]string{"env": "prod", "a.b": "c"},
+			Dates:  map[string]utc.UTC{"start": max},
+		},
+	}
+
+	for i, test := range table {
+		q, err := httputil.EncodeQuery(&test)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		// Go through the wire format
+		q, err = url.ParseQuery(q.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := dummyRoundTrip{}
+		if err := httputil.ParseQuery(q, &res); err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test, res) {
+			t.Errorf("#%d - expect to get %+v, but got %+v (%s)", i, test, res, q.Encode())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package httputil
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/deixis/pkg/unit"
+)
+
+// LimitBody returns a middleware which caps the size of request bodies.
+//
+// Requests declaring a larger Content-Length are rejected with a
+// 413 Request Entity Too Large. Otherwise, reading beyond the limit returns
+// a *unit.TooLargeError to the handler. When the handler does not reply
+// after such an error, the middleware replies with a 413 on its behalf.
+func LimitBody(limit unit.Byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if unit.Byte(r.ContentLength) > limit {
+				WriteTooLarge(w, limit)
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &limitedBody{
+				Reader: unit.LimitReader(r.Body, limit),
+				Closer: r.Body,
+				w:      w,
+			}
+			lw := &limitedWriter{ResponseWriter: w}
+			r.Body = body
+			next.ServeHTTP(lw, r)
+
+			if body.exceeded && !lw.wroteHeader {
+				WriteTooLarge(w, limit)
+			}
+		})
+	}
+}
+
+// WriteTooLarge replies to the request with a 413 Request Entity Too Large
+// and a message stating the limit.
+func WriteTooLarge(w http.ResponseWriter, limit unit.Byte) {
+	msg := "Request body too large (limit is " + limit.String() + ")\n"
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(msg)))
+	h.Set("Connection", "close")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	io.WriteString(w, msg)
+}
+
+// limitedBody records whether the limit has been exceeded
+type limitedBody struct {
+	io.Reader
+	io.Closer
+
+	w        http.ResponseWriter
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if _, ok := err.(*unit.TooLargeError); ok && !b.exceeded {
+		// The rest of the body will not be read, so the connection cannot be reused
+		b.exceeded = true
+		b.w.Header().Set("Connection", "close")
+	}
+	return n, err
+}
+
+// limitedWriter records whether the handler has replied
+type limitedWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+}
+
+func (w *limitedWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *limitedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
@@ -0,0 +1,78 @@
+package httputil_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deixis/pkg/httputil"
+)
+
+func TestLimitBody(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		body    string
+		chunked bool
+		handler http.HandlerFunc
+		code    int
+		expect  string
+	}{
+		{
+			body:   "hello",
+			code:   http.StatusOK,
+			expect: "hello",
+		},
+		{
+			body:   "hello world",
+			code:   http.StatusRequestEntityTooLarge,
+			expect: "Request body too large (limit is 8 B)\n",
+		},
+		{
+			body:    "hello world",
+			chunked: true,
+			code:    http.StatusRequestEntityTooLarge,
+			expect:  "Request body too large (limit is 8 B)\n",
+		},
+		{
+			body:    "hello world",
+			chunked: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if _, err := ioutil.ReadAll(r.Body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			},
+			code:   http.StatusBadRequest,
+			expect: "too large: 9 B exceeds the limit of 8 B\n",
+		},
+	}
+
+	for i, test := range table {
+		h := test.handler
+		if h == nil {
+			h = func(w http.ResponseWriter, r *http.Request) {
+				data, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					return
+				}
+				w.Write(data)
+			}
+		}
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+		if test.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		httputil.LimitBody(8)(h).ServeHTTP(rec, req)
+
+		if rec.Code != test.code {
+			t.Errorf("#%d - expect status %d, but got %d", i, test.code, rec.Code)
+		}
+		if rec.Body.String() != test.expect {
+			t.Errorf("#%d - expect body %q, but got %q", i, test.expect, rec.Body.String())
+		}
+	}
+}
@@ -3,10 +3,15 @@ package httputil
 import (
 	"context"
 	"encoding"
+	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/deixis/errors"
 	"github.com/deixis/spine/log"
@@ -30,6 +35,20 @@ func ParseReq(ctx context.Context, r *http.Request, params interface{}) error {
 }
 
 // ParseQuery parses the values of v from the HTTP query
+//
+// Nested struct fields are bound with their tag as prefix, either with dots
+// or brackets (e.g. filter.status=x or filter[status]=x). Embedded structs
+// without tag share the prefix of their parent. Fields of type map[string]T
+// are bound from bracketed keys (e.g. labels[env]=prod).
+//
+// Besides required, tag options set a default value (default=10) and
+// validation rules: enum=asc|desc, minlen=, maxlen=, min= and max= (bounds
+// parsed as the type of the field, e.g. min=1kB for a unit.Byte), and
+// pattern=, which must be the last option since the expression may contain
+// commas.
+//
+// Every invalid parameter is reported with a FieldViolation, and they are all
+// returned together in a single bad request error.
 func ParseQuery(q url.Values, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
@@ -37,78 +56,538 @@ func ParseQuery(q url.Values, v interface{}) error {
 	}
 
 	rv = reflect.Indirect(rv)
-	tv := rv.Type()
 	switch rv.Kind() {
 	case reflect.Struct:
-		for i := 0; i < rv.NumField(); i++ {
-			field := tv.Field(i)
-			val := rv.Field(i)
+		d := queryDecoder{q: normaliseQuery(q)}
+		d.parseStruct(rv, "")
+		if d.err != nil {
+			return d.err
+		}
+		if len(d.violations) > 0 {
+			return errors.Bad(d.violations...)
+		}
+		return nil
+	default:
+		return errors.New("httputil: ParseQuery(unsupported type " + reflect.TypeOf(v).String() + ")")
+	}
+}
 
-			// If the current field has a query string tags
-			tag, ok := field.Tag.Lookup(queryStringTag)
-			if !ok {
-				continue
+// queryDecoder parses a query, and collects its violations
+type queryDecoder struct {
+	q          url.Values
+	violations []*errors.FieldViolation
+	// err is set when a struct tag is invalid
+	err error
+}
+
+// violate records a violation of field
+func (d *queryDecoder) violate(field, description string) {
+	d.violations = append(d.violations, &errors.FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+}
+
+// invalid records a value which cannot be parsed into a value of type t.
+// The reason is given when the type name does not explain it (e.g. a number
+// out of range).
+func (d *queryDecoder) invalid(field string, t reflect.Type, value string, err error) {
+	desc := fmt.Sprintf("Invalid value %q, expect %s", value, typeName(t))
+	numErr, isNumErr := err.(*strconv.NumError)
+	switch {
+	case isTextUnmarshaler(t):
+		desc += " (" + err.Error() + ")"
+	case isNumErr && numErr.Err == strconv.ErrRange:
+		desc += " (" + numErr.Err.Error() + ")"
+	}
+	d.violate(field, desc)
+}
+
+// parseStruct parses the fields of rv, whose keys start with prefix
+func (d *queryDecoder) parseStruct(rv reflect.Value, prefix string) {
+	tv := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		field := tv.Field(i)
+		val := rv.Field(i)
+
+		// If the current field has a query string tags
+		tag, ok := field.Tag.Lookup(queryStringTag)
+		if !ok {
+			// Embedded structs share the prefix of their parent
+			if field.Anonymous && isStructType(field.Type) {
+				d.parseNested(val, prefix)
 			}
-			tag, opts := parseTag(tag)
+			continue
+		}
+		if !val.CanSet() {
+			continue
+		}
+		tag, opts := parseTag(tag)
+		if prefix != "" {
+			tag = prefix + "." + tag
+		}
 
-			// If the query string has the given tag name
-			qVal := q.Get(tag)
-			if qVal == "" {
+		switch {
+		case isSliceType(field.Type):
+			// Slices bind every value of the tag
+			d.parseSlice(tag, opts, val)
+			continue
+		case isMapType(field.Type):
+			d.parseMap(tag, opts, val)
+			continue
+		case isStructType(field.Type):
+			d.parseNested(val, tag)
+			continue
+		}
+
+		// If the query string has the given tag name
+		qVal := d.q.Get(tag)
+		if qVal == "" {
+			def, ok := opts.Get("default")
+			if !ok {
 				if opts.Contains("required") {
-					return errors.Bad(&errors.FieldViolation{
-						Field:       tag,
-						Description: "Missing query string",
-					})
+					d.violate(tag, "Missing query string")
 				}
 				continue
 			}
-			// walk down to get the first non-pointer
-			ut, nptr := indirect(val)
-			if ut != nil {
-				if err := ut.UnmarshalText([]byte(qVal)); err != nil {
-					return errors.WithBad(err)
-				}
-				continue
-			}
-			val = nptr
-
-			// Parse primitives
-			switch reflect.Indirect(val).Kind() {
-			case reflect.Int, reflect.Int32, reflect.Int64:
-				i, err := strconv.ParseInt(qVal, 10, 64)
-				if err != nil {
-					return errors.WithBad(err)
-				}
-				val.SetInt(i)
-			case reflect.Uint, reflect.Uint32, reflect.Uint64:
-				i, err := strconv.ParseUint(qVal, 10, 64)
-				if err != nil {
-					return errors.WithBad(err)
-				}
-				val.SetUint(i)
-			case reflect.Float32, reflect.Float64:
-				f, err := strconv.ParseFloat(qVal, 64)
-				if err != nil {
-					return errors.WithBad(err)
-				}
-				val.SetFloat(f)
-			case reflect.Bool:
-				b, err := strconv.ParseBool(qVal)
-				if err != nil {
-					return errors.WithBad(err)
-				}
-				val.SetBool(b)
-			case reflect.String:
-				val.SetString(qVal)
+			qVal = def
+		}
+		if err := parseValue(val, qVal); err != nil {
+			d.invalid(tag, field.Type, qVal, err)
+			continue
+		}
+		d.validate(tag, opts, qVal, val)
+	}
+}
+
+// parseNested parses a nested struct. Pointers are only allocated when
+// a field of the struct is set. Nil pointers are left untouched, without
+// defaults nor required fields, when the query has no key with their prefix.
+func (d *queryDecoder) parseNested(val reflect.Value, prefix string) {
+	if val.Kind() != reflect.Ptr {
+		d.parseStruct(val, prefix)
+		return
+	}
+	if !val.CanSet() {
+		return
+	}
+	if val.IsNil() && !d.hasPrefix(prefix) {
+		return
+	}
+
+	tmp := reflect.New(val.Type().Elem())
+	d.parseNested(tmp.Elem(), prefix)
+	if !isZero(tmp.Elem()) {
+		val.Set(tmp)
+	}
+}
+
+// hasPrefix returns whether the query has a key starting with prefix. Every
+// key matches the empty prefix of embedded structs.
+func (d *queryDecoder) hasPrefix(prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	for k := range d.q {
+		if strings.HasPrefix(k, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMap parses a map[string]T field from the keys starting with prefix
+// (e.g. labels.env, once normalised from labels[env])
+func (d *queryDecoder) parseMap(prefix string, opts tagOptions, val reflect.Value) {
+	var keys []string
+	for k, v := range d.q {
+		if strings.HasPrefix(k, prefix+".") && len(k) > len(prefix)+1 && len(v) > 0 && v[0] != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	// walk down to the map, allocating pointers as needed
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+	for _, k := range keys {
+		elem := reflect.New(val.Type().Elem()).Elem()
+		if err := parseValue(elem, d.q.Get(k)); err != nil {
+			d.invalid(k, elem.Type(), d.q.Get(k), err)
+			continue
+		}
+		if !d.validate(k, opts, d.q.Get(k), elem) {
+			continue
+		}
+		key := reflect.ValueOf(k[len(prefix)+1:]).Convert(val.Type().Key())
+		val.SetMapIndex(key, elem)
+	}
+}
+
+// validate checks the value s, parsed into val, against the rules set in
+// the tag options, and returns whether it is valid.
+//
+// Items of slices and values of maps are checked individually.
+func (d *queryDecoder) validate(field string, opts tagOptions, s string, val reflect.Value) bool {
+	if enum, ok := opts.Get("enum"); ok {
+		valid := false
+		for _, e := range strings.Split(enum, "|") {
+			if s == e {
+				valid = true
+				break
 			}
 		}
+		if !valid {
+			d.violate(field, "Must be one of "+strings.Replace(enum, "|", ", ", -1))
+			return false
+		}
+	}
+	if pattern, ok := opts.Get("pattern"); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			d.err = errors.New("httputil: invalid pattern option of " + field + ": " + err.Error())
+			return false
+		}
+		if !re.MatchString(s) {
+			d.violate(field, "Must match the pattern "+pattern)
+			return false
+		}
+	}
+	if min, ok := opts.Get("minlen"); ok {
+		n, err := strconv.Atoi(min)
+		if err != nil {
+			d.err = errors.New("httputil: invalid minlen option of " + field)
+			return false
+		}
+		if utf8.RuneCountInString(s) < n {
+			d.violate(field, "Must be at least "+min+" characters long")
+			return false
+		}
+	}
+	if max, ok := opts.Get("maxlen"); ok {
+		n, err := strconv.Atoi(max)
+		if err != nil {
+			d.err = errors.New("httputil: invalid maxlen option of " + field)
+			return false
+		}
+		if utf8.RuneCountInString(s) > n {
+			d.violate(field, "Must be at most "+max+" characters long")
+			return false
+		}
+	}
+	if min, ok := opts.Get("min"); ok {
+		c, err := compareBound(val, min)
+		if err != nil {
+			d.err = errors.New("httputil: invalid min option of " + field + ": " + err.Error())
+			return false
+		}
+		if c < 0 {
+			d.violate(field, "Must be greater than or equal to "+min)
+			return false
+		}
+	}
+	if max, ok := opts.Get("max"); ok {
+		c, err := compareBound(val, max)
+		if err != nil {
+			d.err = errors.New("httputil: invalid max option of " + field + ": " + err.Error())
+			return false
+		}
+		if c > 0 {
+			d.violate(field, "Must be less than or equal to "+max)
+			return false
+		}
+	}
+	return true
+}
+
+// compareBound parses bound as the type of val, and returns -1, 0 or 1 when
+// val is respectively lower than, equal to, or greater than bound
+func compareBound(val reflect.Value, bound string) (int, error) {
+	for val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	b := reflect.New(val.Type()).Elem()
+	if err := parseValue(b, bound); err != nil {
+		return 0, err
+	}
+
+	var lt, gt bool
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		lt, gt = val.Int() < b.Int(), val.Int() > b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		lt, gt = val.Uint() < b.Uint(), val.Uint() > b.Uint()
+	case reflect.Float32, reflect.Float64:
+		lt, gt = val.Float() < b.Float(), val.Float() > b.Float()
 	default:
-		return errors.New("httputil: ParseQuery(unsupported type " + reflect.TypeOf(v).String() + ")")
+		return 0, errors.New("cannot compare " + val.Type().String())
+	}
+	switch {
+	case lt:
+		return -1, nil
+	case gt:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// patterns caches the regular expressions of pattern options
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// parseValue parses s into val, allocating pointers as needed
+func parseValue(val reflect.Value, s string) error {
+	// walk down to get the first non-pointer
+	ut, val := indirect(val)
+	if ut != nil {
+		return ut.UnmarshalText([]byte(s))
 	}
 
+	// Parse primitives
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.String:
+		val.SetString(s)
+	}
 	return nil
 }
 
+// parseSlice parses the values of a slice field. Values are either sent with
+// repeated keys (e.g. id=1&id=2), or joined with the delimiter set by the
+// comma, space or pipe options (e.g. ids=1,2). Empty items are ignored.
+//
+// The minitems and maxitems options bound the number of items of slices
+// which are sent (e.g. qs:"ids,comma,minitems=1,maxitems=50").
+func (d *queryDecoder) parseSlice(tag string, opts tagOptions, val reflect.Value) {
+	var items []string
+	sep := opts.delimiter()
+	for _, v := range d.q[tag] {
+		if sep == "" {
+			if v != "" {
+				items = append(items, v)
+			}
+			continue
+		}
+		for _, item := range strings.Split(v, sep) {
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		if opts.Contains("required") {
+			d.violate(tag, "Missing query string")
+		}
+		return
+	}
+	min, hasMin, err := opts.Int("minitems")
+	if err != nil {
+		d.err = errors.New("httputil: invalid minitems option of " + tag)
+		return
+	}
+	max, hasMax, err := opts.Int("maxitems")
+	if err != nil {
+		d.err = errors.New("httputil: invalid maxitems option of " + tag)
+		return
+	}
+	if hasMin && len(items) < min {
+		d.violate(tag, "Expect at least "+strconv.Itoa(min)+" items")
+		return
+	}
+	if hasMax && len(items) > max {
+		d.violate(tag, "Expect at most "+strconv.Itoa(max)+" items")
+		return
+	}
+
+	// walk down to the slice, allocating pointers as needed
+	t := val.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	slice := reflect.MakeSlice(t, len(items), len(items))
+	for i, item := range items {
+		// Only the first invalid item is reported
+		if err := parseValue(slice.Index(i), item); err != nil {
+			d.invalid(tag, t.Elem(), item, err)
+			return
+		}
+		if !d.validate(tag, opts, item, slice.Index(i)) {
+			return
+		}
+	}
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	val.Set(slice)
+}
+
+// typeName returns the name of the type expected by a field of type t
+// (e.g. integer, utc.UTC)
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr && !isTextUnmarshaler(t) {
+		t = t.Elem()
+	}
+	if isTextUnmarshaler(t) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return t.String()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "unsigned integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	}
+	return t.String()
+}
+
+// isTextUnmarshaler returns whether a value of type t, or a pointer to it,
+// unmarshals itself
+func isTextUnmarshaler(t reflect.Type) bool {
+	textUnmarshaler := reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	return t.Implements(textUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler)
+}
+
+// isSliceType returns whether t is a slice, or a pointer to a slice, which
+// does not unmarshal itself. Byte slices are not lists, so they are ignored
+// like any other unsupported type.
+func isSliceType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr && !isTextUnmarshaler(t) {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 &&
+		!isTextUnmarshaler(t)
+}
+
+// isStructType returns whether t is a struct, or a pointer to a struct,
+// which does not unmarshal itself
+func isStructType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr && !isTextUnmarshaler(t) {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isTextUnmarshaler(t)
+}
+
+// isMapType returns whether t is a map with string keys, or a pointer to it
+func isMapType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
+
+// isZero returns whether v is the zero value of its type
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// normaliseQuery rewrites bracketed keys with dots (e.g. filter[status] to
+// filter.status). Empty brackets are dropped (e.g. id[] to id).
+func normaliseQuery(q url.Values) url.Values {
+	bracketed := false
+	for k := range q {
+		if strings.Contains(k, "[") {
+			bracketed = true
+			break
+		}
+	}
+	if !bracketed {
+		return q
+	}
+
+	n := make(url.Values, len(q))
+	for k, v := range q {
+		key := normaliseKey(k)
+		n[key] = append(n[key], v...)
+	}
+	return n
+}
+
+func normaliseKey(k string) string {
+	i := strings.IndexByte(k, '[')
+	if i <= 0 {
+		return k
+	}
+
+	var b strings.Builder
+	b.WriteString(k[:i])
+	for rest := k[i:]; rest != ""; {
+		if rest[0] != '[' {
+			j := strings.IndexByte(rest, '[')
+			if j < 0 {
+				j = len(rest)
+			}
+			b.WriteString(rest[:j])
+			rest = rest[j:]
+			continue
+		}
+		j := strings.IndexByte(rest, ']')
+		if j < 0 {
+			return k // malformed keys are left as they are
+		}
+		if j > 1 {
+			b.WriteByte('.')
+			b.WriteString(rest[1:j])
+		}
+		rest = rest[j+1:]
+	}
+	return b.String()
+}
+
 // indirect walks down v allocating pointers as needed, until it gets to a non-pointer.
 func indirect(v reflect.Value) (encoding.TextUnmarshaler, reflect.Value) {
 	// If v is a named type and is addressable,
@@ -156,6 +635,54 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions("")
 }
 
+// delimiter returns the separator of the items of a slice, or an empty
+// string when items are sent with repeated keys
+func (o tagOptions) delimiter() string {
+	switch {
+	case o.Contains("comma"):
+		return ","
+	case o.Contains("space"):
+		return " "
+	case o.Contains("pipe"):
+		return "|"
+	}
+	return ""
+}
+
+// Get returns the value of a key=value option
+func (o tagOptions) Get(name string) (string, bool) {
+	s, pattern, hasPattern := o.split()
+	if name == "pattern" {
+		return pattern, hasPattern
+	}
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, name+"=") {
+			return s[len(name)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
+
+// Int returns the value of a key=value option holding an integer. It fails
+// when the option is set to something else.
+func (o tagOptions) Int(name string) (int, bool, error) {
+	v, ok := o.Get(name)
+	if !ok {
+		return 0, false, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false, err
+	}
+	return i, true, nil
+}
+
 // Contains reports whether a comma-separated list of options
 // contains a particular substr flag. substr must be surrounded by a
 // string boundary or commas.
@@ -163,7 +690,7 @@ func (o tagOptions) Contains(optionName string) bool {
 	if len(o) == 0 {
 		return false
 	}
-	s := string(o)
+	s, _, _ := o.split()
 	for s != "" {
 		var next string
 		i := strings.Index(s, ",")
@@ -177,3 +704,16 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// split separates the pattern option, which must be the last option as it
+// may contain commas, from the other options
+func (o tagOptions) split() (opts, pattern string, ok bool) {
+	s := string(o)
+	if strings.HasPrefix(s, "pattern=") {
+		return "", s[len("pattern="):], true
+	}
+	if i := strings.Index(s, ",pattern="); i >= 0 {
+		return s[:i], s[i+len(",pattern="):], true
+	}
+	return s, "", false
+}
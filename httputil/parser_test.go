@@ -3,9 +3,13 @@ package httputil_test
 import (
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/deixis/errors"
 	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/unit"
 	"github.com/deixis/pkg/utc"
 )
 
@@ -105,6 +109,399 @@ func TestParseQueryRequired(t *testing.T) {
 	}
 }
 
+type dummyParseQueryUnit struct {
+	TTL  unit.Duration `qs:"ttl"`
+	Size *unit.Byte    `qs:"size"`
+}
+
+func TestParseQueryUnit(t *testing.T) {
+	t.Parallel()
+
+	size := 3 * unit.MB
+
+	table := []struct {
+		input  url.Values
+		expect dummyParseQueryUnit
+		err    bool
+	}{
+		{input: parseQuery(t, "ttl=7d"), expect: dummyParseQueryUnit{TTL: 7 * unit.Day}},
+		{input: parseQuery(t, "ttl=2w+3d+4h"), expect: dummyParseQueryUnit{TTL: 2*unit.Week + 3*unit.Day + unit.Duration(4*time.Hour)}},
+		{input: parseQuery(t, "ttl=1.5h&size=3+MB"), expect: dummyParseQueryUnit{TTL: unit.Duration(90 * time.Minute), Size: &size}},
+		{input: parseQuery(t, "ttl=7"), err: true},
+		{input: parseQuery(t, "ttl=1e9999999h"), err: true},
+	}
+
+	for i, test := range table {
+		res := dummyParseQueryUnit{}
+		err := httputil.ParseQuery(test.input, &res)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect to get error", i)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.expect, res) {
+			t.Errorf("#%d - expect to get %v, but got %v", i, test.expect, res)
+		}
+	}
+}
+
+type dummyParseQuerySlice struct {
+	IDs    []int64      `qs:"id"`
+	Names  []string     `qs:"names,comma"`
+	Tags   []string     `qs:"tags,space"`
+	Flags  *[]bool      `qs:"flags,pipe"`
+	Dates  []utc.UTC    `qs:"date"`
+	Sizes  []*unit.Byte `qs:"sizes,comma"`
+	Scores []float64    `qs:"score,comma,minitems=2,maxitems=3"`
+	Raw    []byte       `qs:"raw"`
+}
+
+func TestParseQuerySlice(t *testing.T) {
+	t.Parallel()
+
+	a := parseUTC(t, "2027-12-20T14:00:00Z")
+	b := parseUTC(t, "2028-01-01T00:00:00Z")
+	kb, mb := unit.KB, unit.MB
+	flags := []bool{true, false}
+
+	table := []struct {
+		input  url.Values
+		expect dummyParseQuerySlice
+		err    bool
+	}{
+		{input: parseQuery(t, ""), expect: dummyParseQuerySlice{}},
+		{input: parseQuery(t, "id=1&id=2&id=3"), expect: dummyParseQuerySlice{IDs: []int64{1, 2, 3}}},
+		{input: parseQuery(t, "id=1&id=&id=3"), expect: dummyParseQuerySlice{IDs: []int64{1, 3}}},
+		{input: parseQuery(t, "names=a,b,,c"), expect: dummyParseQuerySlice{Names: []string{"a", "b", "c"}}},
+		{input: parseQuery(t, "names=a,b&names=c"), expect: dummyParseQuerySlice{Names: []string{"a", "b", "c"}}},
+		{input: parseQuery(t, "tags=a+b"), expect: dummyParseQuerySlice{Tags: []string{"a", "b"}}},
+		{input: parseQuery(t, "flags=true|false"), expect: dummyParseQuerySlice{Flags: &flags}},
+		{input: parseQuery(t, "date=2027-12-20T14:00:00Z&date=2028-01-01T00:00:00Z"), expect: dummyParseQuerySlice{Dates: []utc.UTC{a, b}}},
+		{input: parseQuery(t, "sizes=1kB,1MB"), expect: dummyParseQuerySlice{Sizes: []*unit.Byte{&kb, &mb}}},
+		{input: parseQuery(t, "score=1.5,2"), expect: dummyParseQuerySlice{Scores: []float64{1.5, 2}}},
+		{input: parseQuery(t, "score=1,2,3"), expect: dummyParseQuerySlice{Scores: []float64{1, 2, 3}}},
+		{input: parseQuery(t, "raw=abc"), expect: dummyParseQuerySlice{}},
+		{input: parseQuery(t, "score=1"), err: true},
+		{input: parseQuery(t, "score=1,2,3,4"), err: true},
+		{input: parseQuery(t, "id=1&id=a"), err: true},
+		{input: parseQuery(t, "date=2027"), err: true},
+	}
+
+	for i, test := range table {
+		res := dummyParseQuerySlice{}
+		err := httputil.ParseQuery(test.input, &res)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect to get error", i)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.expect, res) {
+			t.Errorf("#%d - expect to get %v, but got %v", i, test.expect, res)
+		}
+	}
+}
+
+type dummyPagination struct {
+	Limit        uint   `qs:"limit"`
+	Continuation string `qs:"continuation"`
+}
+
+type dummyFilter struct {
+	Status string   `qs:"status"`
+	Tags   []string `qs:"tags,comma"`
+	Range  *struct {
+		Min int `qs:"min"`
+		Max int `qs:"max"`
+	} `qs:"range"`
+}
+
+type dummyParseQueryNested struct {
+	dummyPagination
+	Filter dummyFilter       `qs:"filter"`
+	Extra  *dummyFilter      `qs:"extra"`
+	Labels map[string]string `qs:"labels"`
+	Counts map[string]int    `qs:"counts"`
+	Since  *utc.UTC          `qs:"since"`
+}
+
+func TestParseQueryNested(t *testing.T) {
+	t.Parallel()
+
+	since := parseUTC(t, "2027-12-20T14:00:00Z")
+
+	table := []struct {
+		input  url.Values
+		expect dummyParseQueryNested
+		err    bool
+	}{
+		{input: parseQuery(t, ""), expect: dummyParseQueryNested{}},
+		{
+			input:  parseQuery(t, "limit=10&continuation=abc"),
+			expect: dummyParseQueryNested{dummyPagination: dummyPagination{Limit: 10, Continuation: "abc"}},
+		},
+		{
+			input:  parseQuery(t, "filter.status=open&filter.tags=a,b"),
+			expect: dummyParseQueryNested{Filter: dummyFilter{Status: "open", Tags: []string{"a", "b"}}},
+		},
+		{
+			input:  parseQuery(t, "filter[status]=open&filter[tags]=a,b"),
+			expect: dummyParseQueryNested{Filter: dummyFilter{Status: "open", Tags: []string{"a", "b"}}},
+		},
+		{
+			input: parseQuery(t, "extra[range][min]=1&extra.range.max=5"),
+			expect: dummyParseQueryNested{Extra: &dummyFilter{Range: &struct {
+				Min int `qs:"min"`
+				Max int `qs:"max"`
+			}{Min: 1, Max: 5}}},
+		},
+		{
+			input: parseQuery(t, "labels[env]=prod&labels[team]=core&counts[a]=1&counts[b]=2&since=2027-12-20T14:00:00Z"),
+			expect: dummyParseQueryNested{
+				Labels: map[string]string{"env": "prod", "team": "core"},
+				Counts: map[string]int{"a": 1, "b": 2},
+				Since:  &since,
+			},
+		},
+		{input: parseQuery(t, "counts[a]=x"), err: true},
+		{input: parseQuery(t, "filter[range][min]=x"), err: true},
+	}
+
+	for i, test := range table {
+		res := dummyParseQueryNested{}
+		err := httputil.ParseQuery(test.input, &res)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect to get error", i)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.expect, res) {
+			t.Errorf("#%d - expect to get %+v, but got %+v", i, test.expect, res)
+		}
+	}
+}
+
+type dummyOptionalFilter struct {
+	Status string `qs:"status,required"`
+	N      int    `qs:"n,default=5"`
+}
+
+type dummyParseQueryOptional struct {
+	Filter *dummyOptionalFilter `qs:"filter"`
+}
+
+// TestParseQueryOptional ensures that rules of optional nested structs only
+// apply when the client sends them
+func TestParseQueryOptional(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		input  url.Values
+		expect dummyParseQueryOptional
+		err    bool
+	}{
+		{input: parseQuery(t, ""), expect: dummyParseQueryOptional{}},
+		{input: parseQuery(t, "other=1"), expect: dummyParseQueryOptional{}},
+		{
+			input:  parseQuery(t, "filter[status]=open"),
+			expect: dummyParseQueryOptional{Filter: &dummyOptionalFilter{Status: "open", N: 5}},
+		},
+		{input: parseQuery(t, "filter.n=3"), err: true},
+	}
+
+	for i, test := range table {
+		res := dummyParseQueryOptional{}
+		err := httputil.ParseQuery(test.input, &res)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.expect, res) {
+			t.Errorf("#%d - expect to get %+v, but got %+v", i, test.expect, res)
+		}
+	}
+}
+
+type dummyParseQueryErrors struct {
+	Limit  uint8          `qs:"limit"`
+	Min    utc.UTC        `qs:"min,required"`
+	IDs    []int64        `qs:"id"`
+	B      *bool          `qs:"b"`
+	Filter dummyFilter    `qs:"filter"`
+	Counts map[string]int `qs:"counts"`
+	Names  []string       `qs:"names,comma,maxitems=2"`
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	q := parseQuery(t, "limit=300&id=1&id=x&id=y&b=maybe&filter[range][min]=1.5&counts[a]=1&counts[b]=-&names=a,b,c")
+	err := httputil.ParseQuery(q, &dummyParseQueryErrors{})
+	bad, ok := err.(*errors.BadRequest)
+	if !ok {
+		t.Fatalf("expect a bad request, but got %v", err)
+	}
+
+	expect := []*errors.FieldViolation{
+		{Field: "limit", Description: `Invalid value "300", expect unsigned integer (value out of range)`},
+		{Field: "min", Description: "Missing query string"},
+		{Field: "id", Description: `Invalid value "x", expect integer`},
+		{Field: "b", Description: `Invalid value "maybe", expect boolean`},
+		{Field: "filter.range.min", Description: `Invalid value "1.5", expect integer`},
+		{Field: "counts.b", Description: `Invalid value "-", expect integer`},
+		{Field: "names", Description: "Expect at most 2 items"},
+	}
+	if !reflect.DeepEqual(expect, bad.Violations) {
+		t.Errorf("expect violations %v, but got %v", expect, bad.Violations)
+	}
+
+	q = parseQuery(t, "min=2027")
+	err = httputil.ParseQuery(q, &dummyParseQueryErrors{})
+	bad, ok = err.(*errors.BadRequest)
+	if !ok || len(bad.Violations) != 1 {
+		t.Fatalf("expect a single violation, but got %v", err)
+	}
+	v := bad.Violations[0]
+	if v.Field != "min" || !strings.HasPrefix(v.Description, `Invalid value "2027", expect utc.UTC (`) {
+		t.Errorf("unexpected violation %v", v)
+	}
+}
+
+type dummyParseQueryRules struct {
+	Limit  int             `qs:"limit,default=20,min=1,max=100"`
+	Sort   string          `qs:"sort,default=asc,enum=asc|desc"`
+	Name   string          `qs:"name,minlen=2,maxlen=5"`
+	Since  *utc.UTC        `qs:"since,min=2020-01-01T00:00:00Z"`
+	Size   unit.Byte       `qs:"size,max=1MB"`
+	Ratio  float64         `qs:"ratio,min=0,max=1"`
+	Fields []string        `qs:"fields,comma,enum=id|name|size"`
+	Code   string          `qs:"code,pattern=^[A-Z]{2,3}$"`
+	Scores map[string]uint `qs:"scores,max=10"`
+}
+
+func TestParseQueryRules(t *testing.T) {
+	t.Parallel()
+
+	since := parseUTC(t, "2021-06-01T00:00:00Z")
+
+	table := []struct {
+		input  url.Values
+		expect dummyParseQueryRules
+		fields []string
+	}{
+		{
+			input:  parseQuery(t, ""),
+			expect: dummyParseQueryRules{Limit: 20, Sort: "asc"},
+		},
+		{
+			input: parseQuery(t, "limit=100&sort=desc&name=ab&since=2021-06-01T00:00:00Z&size=1MB&ratio=0.5&fields=id,size&code=CHE&scores[a]=10"),
+			expect: dummyParseQueryRules{
+				Limit:  100,
+				Sort:   "desc",
+				Name:   "ab",
+				Since:  &since,
+				Size:   unit.MB,
+				Ratio:  0.5,
+				Fields: []string{"id", "size"},
+				Code:   "CHE",
+				Scores: map[string]uint{"a": 10},
+			},
+		},
+		{input: parseQuery(t, "limit=0"), fields: []string{"limit"}},
+		{input: parseQuery(t, "limit=101&sort=up"), fields: []string{"limit", "sort"}},
+		{input: parseQuery(t, "name=a"), fields: []string{"name"}},
+		{input: parseQuery(t, "name=abcdef"), fields: []string{"name"}},
+		{input: parseQuery(t, "name=été"), expect: dummyParseQueryRules{Limit: 20, Sort: "asc", Name: "été"}},
+		{input: parseQuery(t, "since=2019-12-31T23:59:59Z"), fields: []string{"since"}},
+		{input: parseQuery(t, "size=1.5MB&ratio=-0.1"), fields: []string{"size", "ratio"}},
+		{input: parseQuery(t, "fields=id,owner"), fields: []string{"fields"}},
+		{input: parseQuery(t, "code=ch&scores[a]=11&scores[b]=1"), fields: []string{"code", "scores.a"}},
+	}
+
+	for i, test := range table {
+		res := dummyParseQueryRules{}
+		err := httputil.ParseQuery(test.input, &res)
+		if test.fields == nil {
+			if err != nil {
+				t.Errorf("#%d - unexpected error %s", i, err)
+			} else if !reflect.DeepEqual(test.expect, res) {
+				t.Errorf("#%d - expect to get %+v, but got %+v", i, test.expect, res)
+			}
+			continue
+		}
+
+		bad, ok := err.(*errors.BadRequest)
+		if !ok {
+			t.Errorf("#%d - expect a bad request, but got %v", i, err)
+			continue
+		}
+		var fields []string
+		for _, v := range bad.Violations {
+			fields = append(fields, v.Field)
+		}
+		if !reflect.DeepEqual(test.fields, fields) {
+			t.Errorf("#%d - expect violations of %v, but got %v", i, test.fields, bad.Violations)
+		}
+	}
+}
+
+func TestParseQueryRulesDescription(t *testing.T) {
+	t.Parallel()
+
+	err := httputil.ParseQuery(parseQuery(t, "limit=0&sort=up&code=x"), &dummyParseQueryRules{})
+	bad, ok := err.(*errors.BadRequest)
+	if !ok {
+		t.Fatalf("expect a bad request, but got %v", err)
+	}
+	expect := []*errors.FieldViolation{
+		{Field: "limit", Description: "Must be greater than or equal to 1"},
+		{Field: "sort", Description: "Must be one of asc, desc"},
+		{Field: "code", Description: "Must match the pattern ^[A-Z]{2,3}$"},
+	}
+	if !reflect.DeepEqual(expect, bad.Violations) {
+		t.Errorf("expect violations %v, but got %v", expect, bad.Violations)
+	}
+}
+
+func TestParseQueryInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	table := []interface{}{
+		&struct {
+			A int `qs:"a,min=x"`
+		}{},
+		&struct {
+			A string `qs:"a,pattern=["`
+		}{},
+		&struct {
+			A string `qs:"a,maxlen=x"`
+		}{},
+		&struct {
+			A bool `qs:"a,max=true"`
+		}{},
+		&struct {
+			A []int `qs:"a,minitems=x"`
+		}{},
+		&struct {
+			A []string `qs:"a,comma,maxitems=1.5"`
+		}{},
+	}
+	for i, v := range table {
+		err := httputil.ParseQuery(parseQuery(t, "a=1"), v)
+		if err == nil {
+			t.Errorf("#%d - expect an error", i)
+			continue
+		}
+		if _, ok := err.(*errors.BadRequest); ok {
+			t.Errorf("#%d - expect an invalid tag error, but got %v", i, err)
+		}
+	}
+}
+
 func parseQuery(t *testing.T, s string) url.Values {
 	v, err := url.ParseQuery(s)
 	if err != nil {
@@ -2,12 +2,27 @@ package httputil
 
 import (
 	"errors"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 )
 
+const contentRange = "Content-Range"
+
+// UnknownSize is the size of a range whose complete length is unknown
+const UnknownSize int64 = -1
+
+// HTTPRange is a range of a resource, as found in a Content-Range header.
+// End is inclusive.
+//
+// A range with a negative Start and End is unsatisfied (e.g. bytes */1234),
+// and a range with an UnknownSize does not know the complete length of the
+// resource (e.g. bytes 0-99/*).
 type HTTPRange struct {
 	Start, End, Size int64
+	// Unit is the range unit (e.g. items), which is empty for bytes
+	Unit string
 }
 
 // ErrInvalidFormat is returned when it is an invalid range format
@@ -27,26 +42,43 @@ func ParseContentRange(s string) (*HTTPRange, error) {
 		return nil, ErrInvalidFormat // header not present
 	}
 
-	// Only bytes are supported (for now)
-	const b = "bytes "
-	if !strings.HasPrefix(s, b) {
+	// The unit must be registered (see RegisterRangeUnit)
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return nil, ErrInvalidFormat
+	}
+	unit, ok := lookupRangeUnit(s[:i])
+	if !ok {
 		return nil, ErrInvalidFormat
 	}
 
-	r := strings.Split(s[len(b):], "/")
+	r := strings.Split(s[i+1:], "/")
 	if len(r) != 2 {
 		return nil, ErrInvalidFormat
 	}
-	ran := r[0]
+	ran := strings.TrimSpace(r[0])
 	size := strings.TrimSpace(r[1])
 
 	// Parse size
-	i, err := strconv.ParseInt(size, 10, 64)
-	if err != nil {
-		return nil, ErrInvalidFormat
-	}
 	httpRange := &HTTPRange{
-		Size: i,
+		Size: UnknownSize,
+		Unit: unit,
+	}
+	if size != "*" {
+		i, err := parsePosition(size)
+		if err != nil {
+			return nil, ErrInvalidFormat
+		}
+		httpRange.Size = i
+	}
+
+	// Unsatisfied range
+	if ran == "*" {
+		if httpRange.Size == UnknownSize {
+			return nil, ErrInvalidFormat
+		}
+		httpRange.Start, httpRange.End = -1, -1
+		return httpRange, nil
 	}
 
 	// Parse ranges
@@ -57,24 +89,71 @@ func ParseContentRange(s string) (*HTTPRange, error) {
 	start := strings.TrimSpace(r[0])
 	end := strings.TrimSpace(r[1])
 
-	i, err = strconv.ParseInt(start, 10, 64)
+	pos, err := parsePosition(start)
 	if err != nil {
 		return nil, ErrInvalidFormat
 	}
-	httpRange.Start = i
-	i, err = strconv.ParseInt(end, 10, 64)
+	httpRange.Start = pos
+	pos, err = parsePosition(end)
 	if err != nil {
 		return nil, ErrInvalidFormat
 	}
-	httpRange.End = i
+	httpRange.End = pos
 
 	// Ensure range validity
 	if httpRange.Start > httpRange.End {
 		return nil, ErrInvalidRange
 	}
-	if httpRange.End > httpRange.Size {
+	// Positions are zero-based, so the last byte is at Size-1 (RFC 9110)
+	if httpRange.Size != UnknownSize && httpRange.End >= httpRange.Size {
 		return nil, ErrInvalidRange
 	}
 
 	return httpRange, nil
 }
+
+// FormatContentRange formats the `Content-Range` response header
+func FormatContentRange(h http.Header, r HTTPRange) {
+	h.Set(contentRange, r.String())
+}
+
+// String returns the representation of r used by the Content-Range header
+//
+// e.g. bytes 0-99/1234, bytes 0-99/*, bytes */1234, items 0-24/100
+func (r HTTPRange) String() string {
+	unit := r.Unit
+	if unit == "" {
+		unit = BytesUnit
+	}
+	size := "*"
+	if r.Size != UnknownSize {
+		size = strconv.FormatInt(r.Size, 10)
+	}
+	if r.Unsatisfied() {
+		return unit + " */" + size
+	}
+	return unit + " " + strconv.FormatInt(r.Start, 10) + "-" + strconv.FormatInt(r.End, 10) + "/" + size
+}
+
+// Unsatisfied returns whether r does not cover any byte of the resource
+func (r HTTPRange) Unsatisfied() bool {
+	return r.Start < 0 || r.End < 0
+}
+
+// Length returns the number of units covered by r
+func (r HTTPRange) Length() int64 {
+	if r.Unsatisfied() {
+		return 0
+	}
+	return r.End - r.Start + 1
+}
+
+// Contains returns whether the unit at position pos is covered by r
+func (r HTTPRange) Contains(pos int64) bool {
+	return !r.Unsatisfied() && r.Start <= pos && pos <= r.End
+}
+
+// SectionReader returns a reader of the bytes of ra covered by r
+func (r HTTPRange) SectionReader(ra io.ReaderAt) *io.SectionReader {
+	return io.NewSectionReader(ra, r.Start, r.Length())
+}
@@ -1,6 +1,9 @@
 package httputil_test
 
 import (
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/deixis/pkg/httputil"
@@ -24,11 +27,15 @@ func TestParseContentRange(t *testing.T) {
 			size:  128,
 		},
 		{
-			in:    "bytes 64-128/128",
+			in:    "bytes 64-127/128",
 			start: 64,
-			end:   128,
+			end:   127,
 			size:  128,
 		},
+		{
+			in:  "bytes 0-100/100",
+			err: httputil.ErrInvalidRange,
+		},
 		{
 			in:  "",
 			err: httputil.ErrInvalidFormat,
@@ -53,6 +60,26 @@ func TestParseContentRange(t *testing.T) {
 			in:  "bytes 129-193/128",
 			err: httputil.ErrInvalidRange,
 		},
+		{
+			in:    "bytes */1234",
+			start: -1,
+			end:   -1,
+			size:  1234,
+		},
+		{
+			in:    "bytes 0-99/*",
+			start: 0,
+			end:   99,
+			size:  httputil.UnknownSize,
+		},
+		{
+			in:  "bytes */*",
+			err: httputil.ErrInvalidFormat,
+		},
+		{
+			in:  "bytes 0-99/-1",
+			err: httputil.ErrInvalidFormat,
+		},
 	}
 
 	for i, test := range table {
@@ -75,3 +102,61 @@ func TestParseContentRange(t *testing.T) {
 		}
 	}
 }
+
+// TestFormatContentRange ensures that formatted ranges can be parsed back
+func TestFormatContentRange(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     httputil.HTTPRange
+		expect string
+		length int64
+	}{
+		{in: httputil.HTTPRange{Start: 0, End: 99, Size: 1234}, expect: "bytes 0-99/1234", length: 100},
+		{in: httputil.HTTPRange{Start: 0, End: 99, Size: httputil.UnknownSize}, expect: "bytes 0-99/*", length: 100},
+		{in: httputil.HTTPRange{Start: -1, End: -1, Size: 1234}, expect: "bytes */1234", length: 0},
+	}
+
+	for i, test := range table {
+		h := http.Header{}
+		httputil.FormatContentRange(h, test.in)
+		got := h.Get("Content-Range")
+		if got != test.expect {
+			t.Errorf("#%d - expect `Content-Range` to be %s, but got %s", i, test.expect, got)
+		}
+		if test.in.Length() != test.length {
+			t.Errorf("#%d - expect length %d, but got %d", i, test.length, test.in.Length())
+		}
+
+		res, err := httputil.ParseContentRange(got)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		if *res != test.in {
+			t.Errorf("#%d - expect to parse back %v, but got %v", i, test.in, *res)
+		}
+	}
+}
+
+func TestHTTPRangeHelpers(t *testing.T) {
+	t.Parallel()
+
+	r := httputil.HTTPRange{Start: 2, End: 4, Size: 10}
+	for pos, expect := range []bool{false, false, true, true, true, false} {
+		if r.Contains(int64(pos)) != expect {
+			t.Errorf("expect Contains(%d) to be %t", pos, expect)
+		}
+	}
+	if (httputil.HTTPRange{Start: -1, End: -1, Size: 10}).Contains(0) {
+		t.Error("expect unsatisfied range not to contain anything")
+	}
+
+	data, err := ioutil.ReadAll(r.SectionReader(strings.NewReader("0123456789")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "234" {
+		t.Errorf("expect to read 234, but got %s", data)
+	}
+}
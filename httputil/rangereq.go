@@ -0,0 +1,222 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxRanges is the maximum number of ranges accepted in a Range header, which
+// protects servers against range amplification attacks.
+var MaxRanges = 32
+
+// ErrUnsatisfiableRange is returned when none of the requested ranges overlap
+// with the resource
+var ErrUnsatisfiableRange = errors.New("range not satisfiable")
+
+// ErrTooManyRanges is returned when a Range header has more than MaxRanges ranges
+var ErrTooManyRanges = errors.New("too many ranges")
+
+// RangeSpec is a range requested by a client, before it is resolved against
+// the size of the resource.
+//
+// e.g.
+// 0-499 is {Start: 0, End: 499}
+// 1000- is {Start: 1000, End: -1}
+// -500 is {Start: -1, End: 500}
+type RangeSpec struct {
+	// Start is the first position, or -1 for a suffix range
+	Start int64
+	// End is the last position (inclusive), -1 for an open-ended range, or
+	// the length of a suffix range
+	End int64
+}
+
+// ParseRange parses the HTTP Range request header, and resolves it against
+// the size of the resource. Overlapping and adjacent ranges are coalesced,
+// and the result is sorted.
+//
+// Ranges that do not overlap with the resource are ignored, and
+// ErrUnsatisfiableRange is returned when none of them does.
+//
+// e.g.
+// Range: bytes=0-499
+// Range: bytes=0-499,1000-,-500
+func ParseRange(s string, size int64) ([]HTTPRange, error) {
+	specs, err := ParseRangeSpecs(s)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveRanges(specs, size)
+}
+
+// ParseRangeSpecs parses the HTTP Range request header without resolving it
+func ParseRangeSpecs(s string) ([]RangeSpec, error) {
+	unit, specs, err := ParseUnitRangeSpecs(s)
+	if err != nil {
+		return nil, err
+	}
+	if unit != "" {
+		return nil, ErrInvalidFormat
+	}
+	return specs, nil
+}
+
+// ParseUnitRange parses an HTTP Range request header of any registered unit
+// (e.g. items=0-24), and resolves it against the size of the resource. It
+// follows the rules of ParseRange, and the unit is set on the ranges
+// returned.
+func ParseUnitRange(s string, size int64) ([]HTTPRange, error) {
+	unit, specs, err := ParseUnitRangeSpecs(s)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := ResolveRanges(specs, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ranges {
+		ranges[i].Unit = unit
+	}
+	return ranges, nil
+}
+
+// ParseUnitRangeSpecs parses an HTTP Range request header of any registered
+// unit without resolving it. The unit returned is empty for bytes.
+func ParseUnitRangeSpecs(s string) (string, []RangeSpec, error) {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return "", nil, ErrInvalidFormat
+	}
+	unit, ok := lookupRangeUnit(s[:i])
+	if !ok {
+		return "", nil, ErrInvalidFormat
+	}
+
+	var specs []RangeSpec
+	for _, ran := range strings.Split(s[i+1:], ",") {
+		ran = strings.TrimSpace(ran)
+		if ran == "" {
+			continue // empty list elements are allowed
+		}
+		if len(specs) == MaxRanges {
+			return "", nil, ErrTooManyRanges
+		}
+
+		j := strings.Index(ran, "-")
+		if j < 0 {
+			return "", nil, ErrInvalidFormat
+		}
+		start, end := ran[:j], ran[j+1:]
+
+		spec := RangeSpec{Start: -1, End: -1}
+		var err error
+		if start != "" {
+			if spec.Start, err = parsePosition(start); err != nil {
+				return "", nil, err
+			}
+		}
+		switch {
+		case end != "":
+			if spec.End, err = parsePosition(end); err != nil {
+				return "", nil, err
+			}
+		case start == "":
+			return "", nil, ErrInvalidFormat
+		}
+		if spec.Start >= 0 && spec.End >= 0 && spec.Start > spec.End {
+			return "", nil, ErrInvalidRange
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return "", nil, ErrInvalidFormat
+	}
+	return unit, specs, nil
+}
+
+// FormatRange formats the `Range` request header (e.g. items=0-24). Byte
+// ranges are requested with an empty unit.
+func FormatRange(h http.Header, unit string, specs ...RangeSpec) {
+	if unit == "" {
+		unit = BytesUnit
+	}
+	l := make([]string, len(specs))
+	for i, spec := range specs {
+		l[i] = spec.String()
+	}
+	h.Set("Range", unit+"="+strings.Join(l, ","))
+}
+
+// String returns the representation of s used by the Range header
+//
+// e.g. 0-499, 1000-, -500
+func (s RangeSpec) String() string {
+	switch {
+	case s.Start < 0:
+		return "-" + strconv.FormatInt(s.End, 10)
+	case s.End < 0:
+		return strconv.FormatInt(s.Start, 10) + "-"
+	}
+	return strconv.FormatInt(s.Start, 10) + "-" + strconv.FormatInt(s.End, 10)
+}
+
+// ResolveRanges resolves the requested ranges against the size of the
+// resource. It follows the rules of ParseRange.
+func ResolveRanges(specs []RangeSpec, size int64) ([]HTTPRange, error) {
+	var ranges []HTTPRange
+	for _, spec := range specs {
+		r := HTTPRange{Start: spec.Start, End: spec.End, Size: size}
+		switch {
+		case spec.Start < 0:
+			// Suffix range
+			if spec.End == 0 || size == 0 {
+				continue
+			}
+			r.Start = size - spec.End
+			if r.Start < 0 {
+				r.Start = 0
+			}
+			r.End = size - 1
+		case spec.Start >= size:
+			continue
+		case spec.End < 0 || spec.End >= size:
+			r.End = size - 1
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, ErrUnsatisfiableRange
+	}
+
+	// Coalesce overlapping and adjacent ranges
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	coalesced := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &coalesced[len(coalesced)-1]
+		if r.Start <= last.End+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		coalesced = append(coalesced, r)
+	}
+	return coalesced, nil
+}
+
+// parsePosition parses a range position, which only contains digits
+func parsePosition(s string) (int64, error) {
+	if strings.TrimLeft(s, "0123456789") != "" {
+		return 0, ErrInvalidFormat
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidFormat
+	}
+	return i, nil
+}
@@ -0,0 +1,106 @@
+package httputil_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deixis/pkg/httputil"
+)
+
+// TestParseRange ensures that Range headers are properly parsed and resolved
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		size   int64
+		expect []httputil.HTTPRange
+		err    error
+	}{
+		{
+			in:     "bytes=0-499",
+			size:   10000,
+			expect: []httputil.HTTPRange{{Start: 0, End: 499, Size: 10000}},
+		},
+		{
+			in:   "bytes=0-499,1000-,-500",
+			size: 10000,
+			expect: []httputil.HTTPRange{
+				{Start: 0, End: 499, Size: 10000},
+				{Start: 1000, End: 9999, Size: 10000},
+			},
+		},
+		{
+			in:   "bytes=0-499, 1000-1499 ,-500",
+			size: 10000,
+			expect: []httputil.HTTPRange{
+				{Start: 0, End: 499, Size: 10000},
+				{Start: 1000, End: 1499, Size: 10000},
+				{Start: 9500, End: 9999, Size: 10000},
+			},
+		},
+		{
+			in:     "bytes=500-700,0-499,600-1000",
+			size:   10000,
+			expect: []httputil.HTTPRange{{Start: 0, End: 1000, Size: 10000}},
+		},
+		{
+			in:     "Bytes=0-,,",
+			size:   100,
+			expect: []httputil.HTTPRange{{Start: 0, End: 99, Size: 100}},
+		},
+		{
+			in:     "bytes=-500",
+			size:   100,
+			expect: []httputil.HTTPRange{{Start: 0, End: 99, Size: 100}},
+		},
+		{
+			in:     "bytes=50-1000,200-300",
+			size:   100,
+			expect: []httputil.HTTPRange{{Start: 50, End: 99, Size: 100}},
+		},
+		{in: "bytes=100-", size: 100, err: httputil.ErrUnsatisfiableRange},
+		{in: "bytes=-0", size: 100, err: httputil.ErrUnsatisfiableRange},
+		{in: "bytes=-10", size: 0, err: httputil.ErrUnsatisfiableRange},
+		{in: "bytes=500-400", size: 1000, err: httputil.ErrInvalidRange},
+		{in: "", err: httputil.ErrInvalidFormat},
+		{in: "bytes=", err: httputil.ErrInvalidFormat},
+		{in: "bytes=-", err: httputil.ErrInvalidFormat},
+		{in: "bytes=1", err: httputil.ErrInvalidFormat},
+		{in: "bytes=+1-2", err: httputil.ErrInvalidFormat},
+		{in: "bytes=1--2", err: httputil.ErrInvalidFormat},
+		{in: "items=0-10", err: httputil.ErrInvalidFormat},
+		{in: "bytes=" + strings.Repeat("0-1,", 33), err: httputil.ErrTooManyRanges},
+	}
+
+	for i, test := range table {
+		res, err := httputil.ParseRange(test.in, test.size)
+		if err != test.err {
+			t.Errorf("#%d - expect error <%v>, but got <%v>", i, test.err, err)
+		}
+		if test.err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.expect, res) {
+			t.Errorf("#%d - expect to get %v, but got %v", i, test.expect, res)
+		}
+	}
+}
+
+func TestParseRangeSpecs(t *testing.T) {
+	t.Parallel()
+
+	res, err := httputil.ParseRangeSpecs("bytes=0-499,1000-,-500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []httputil.RangeSpec{
+		{Start: 0, End: 499},
+		{Start: 1000, End: -1},
+		{Start: -1, End: 500},
+	}
+	if !reflect.DeepEqual(expect, res) {
+		t.Errorf("expect to get %v, but got %v", expect, res)
+	}
+}
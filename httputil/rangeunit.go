@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"strings"
+	"sync"
+)
+
+// BytesUnit is the range unit of byte ranges, which is always accepted
+const BytesUnit = "bytes"
+
+var rangeUnits = struct {
+	sync.RWMutex
+	m map[string]bool
+}{
+	m: map[string]bool{BytesUnit: true},
+}
+
+// RegisterRangeUnit accepts unit (e.g. items) in Range and Content-Range
+// headers. Units are case-insensitive.
+//
+// It panics if unit is not a valid token.
+func RegisterRangeUnit(unit string) {
+	if unit == "" || strings.IndexFunc(unit, func(r rune) bool { return !isTokenRune(r) }) >= 0 {
+		panic("httputil: invalid range unit " + unit)
+	}
+
+	rangeUnits.Lock()
+	defer rangeUnits.Unlock()
+	rangeUnits.m[strings.ToLower(unit)] = true
+}
+
+// IsRangeUnit returns whether unit is accepted in Range and Content-Range
+// headers
+func IsRangeUnit(unit string) bool {
+	_, ok := lookupRangeUnit(unit)
+	return ok
+}
+
+// lookupRangeUnit returns the name of the registered unit, which is empty
+// for bytes
+func lookupRangeUnit(unit string) (string, bool) {
+	unit = strings.ToLower(unit)
+
+	rangeUnits.RLock()
+	defer rangeUnits.RUnlock()
+	if !rangeUnits.m[unit] {
+		return "", false
+	}
+	if unit == BytesUnit {
+		return "", true
+	}
+	return unit, true
+}
+
+// isTokenRune returns whether r is allowed in an HTTP token
+func isTokenRune(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
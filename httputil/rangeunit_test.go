@@ -0,0 +1,100 @@
+package httputil_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/deixis/pkg/httputil"
+)
+
+func init() {
+	httputil.RegisterRangeUnit("items")
+}
+
+// TestRangeUnits ensures that registered units are parsed and formatted
+func TestRangeUnits(t *testing.T) {
+	t.Parallel()
+
+	if !httputil.IsRangeUnit("bytes") || !httputil.IsRangeUnit("Items") {
+		t.Error("expect bytes and items to be range units")
+	}
+	if httputil.IsRangeUnit("pages") {
+		t.Error("expect pages not to be a range unit")
+	}
+
+	// Content-Range
+	r, err := httputil.ParseContentRange("items 0-24/100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := httputil.HTTPRange{Start: 0, End: 24, Size: 100, Unit: "items"}
+	if *r != expect {
+		t.Errorf("expect to get %v, but got %v", expect, *r)
+	}
+	if s := r.String(); s != "items 0-24/100" {
+		t.Errorf("expect to format %q, but got %q", "items 0-24/100", s)
+	}
+	r, err = httputil.ParseContentRange("bytes 0-24/100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Unit != "" {
+		t.Errorf("expect bytes to have an empty unit, but got %q", r.Unit)
+	}
+	if _, err := httputil.ParseContentRange("pages 0-24/100"); err != httputil.ErrInvalidFormat {
+		t.Errorf("expect error <%v>, but got <%v>", httputil.ErrInvalidFormat, err)
+	}
+
+	// Range
+	ranges, err := httputil.ParseUnitRange("items=50-,0-24", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRanges := []httputil.HTTPRange{
+		{Start: 0, End: 24, Size: 100, Unit: "items"},
+		{Start: 50, End: 99, Size: 100, Unit: "items"},
+	}
+	if !reflect.DeepEqual(expectRanges, ranges) {
+		t.Errorf("expect to get %v, but got %v", expectRanges, ranges)
+	}
+	unit, specs, err := httputil.ParseUnitRangeSpecs("bytes=-500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit != "" || !reflect.DeepEqual([]httputil.RangeSpec{{Start: -1, End: 500}}, specs) {
+		t.Errorf("unexpected unit %q and specs %v", unit, specs)
+	}
+	if _, err := httputil.ParseUnitRange("pages=0-24", 100); err != httputil.ErrInvalidFormat {
+		t.Errorf("expect error <%v>, but got <%v>", httputil.ErrInvalidFormat, err)
+	}
+	if _, err := httputil.ParseRangeSpecs("items=0-24"); err != httputil.ErrInvalidFormat {
+		t.Errorf("expect error <%v>, but got <%v>", httputil.ErrInvalidFormat, err)
+	}
+
+	h := http.Header{}
+	httputil.FormatRange(h, "items", httputil.RangeSpec{Start: 0, End: 24}, httputil.RangeSpec{Start: 50, End: -1})
+	if v := h.Get("Range"); v != "items=0-24,50-" {
+		t.Errorf("expect Range %q, but got %q", "items=0-24,50-", v)
+	}
+	httputil.FormatRange(h, "", httputil.RangeSpec{Start: -1, End: 500})
+	if v := h.Get("Range"); v != "bytes=-500" {
+		t.Errorf("expect Range %q, but got %q", "bytes=-500", v)
+	}
+}
+
+// TestRegisterRangeUnit_Invalid ensures that invalid units are rejected
+func TestRegisterRangeUnit_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, unit := range []string{"", "two words", "a/b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q - expect a panic", unit)
+				}
+			}()
+			httputil.RegisterRangeUnit(unit)
+		}()
+	}
+}
@@ -0,0 +1,269 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+// DefaultRetryStatuses are the statuses retried by RetryTransport by default
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryAttempt describes a failed attempt which is about to be retried
+type RetryAttempt struct {
+	// Request is the request sent
+	Request *http.Request
+	// Attempt is the number of the attempt which failed, starting at 1
+	Attempt int
+	// Response is the response received, or nil when Err is set. Its body
+	// has been closed.
+	Response *http.Response
+	// Err is the error returned by the transport
+	Err error
+	// Delay is the time waited before the next attempt
+	Delay time.Duration
+}
+
+// RetryTransport is an http.RoundTripper which retries idempotent requests
+// on failure.
+//
+// Requests are idempotent when their method is GET, HEAD, OPTIONS, TRACE,
+// PUT or DELETE, or when they carry an Idempotency-Key header. Attempts are
+// spaced by an exponential backoff with jitter, unless the server sends a
+// Retry-After header. Retries stop early when the request context would
+// expire before the next attempt.
+//
+// Request bodies are replayed with Request.GetBody, or buffered in memory
+// when it is not set.
+type RetryTransport struct {
+	// Transport sends the requests (http.DefaultTransport by default)
+	Transport http.RoundTripper
+	// MaxRetries is the number of retries of a request (3 by default). It can
+	// be overridden per request with WithRetryBudget.
+	MaxRetries int
+	// Statuses are the response statuses retried (DefaultRetryStatuses by
+	// default)
+	Statuses []int
+	// RetryError returns whether a transport error is retried (all errors by
+	// default). Context errors are never retried.
+	RetryError func(err error) bool
+	// MinBackoff is the delay before the first retry (100ms by default)
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay of the exponential backoff (10s by
+	// default)
+	MaxBackoff time.Duration
+	// MaxRetryAfter caps the delays sent by servers with Retry-After (1m by
+	// default)
+	MaxRetryAfter time.Duration
+	// MaxBufferSize is the largest request body buffered to be replayed when
+	// Request.GetBody is not set (1 MiB by default). Larger bodies are not
+	// retried.
+	MaxBufferSize unit.Byte
+	// OnRetry is called before each retry (optional)
+	OnRetry func(a RetryAttempt)
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudget returns a context which sets the number of retries of the
+// requests sent with it, overriding RetryTransport.MaxRetries
+func WithRetryBudget(ctx context.Context, retries int) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, retries)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	budget := t.maxRetries()
+	if v, ok := ctx.Value(retryBudgetKey{}).(int); ok {
+		budget = v
+	}
+	if !isIdempotent(req) || budget <= 0 {
+		return t.transport().RoundTrip(req)
+	}
+
+	getBody, body, err := t.replayableBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if getBody == nil {
+		// The body is too large to be replayed
+		r := req.Clone(ctx)
+		r.Body = body
+		return t.transport().RoundTrip(r)
+	}
+
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(ctx)
+		if req.Body != nil && req.Body != http.NoBody {
+			if r.Body, err = getBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := t.transport().RoundTrip(r)
+		if attempt > budget || !t.retryable(ctx, res, err) {
+			return res, err
+		}
+
+		delay := t.backoff(attempt)
+		if res != nil {
+			if d, ok := ParseRetryAfter(res.Header); ok {
+				delay = d
+				if max := t.maxRetryAfter(); delay > max {
+					delay = max
+				}
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// The next attempt would not complete in time
+			return res, err
+		}
+		if res != nil {
+			drain(res.Body)
+		}
+		if t.OnRetry != nil {
+			t.OnRetry(RetryAttempt{Request: req, Attempt: attempt, Response: res, Err: err, Delay: delay})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable returns whether the outcome of an attempt can be retried
+func (t *RetryTransport) retryable(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return t.RetryError == nil || t.RetryError(err)
+	}
+
+	statuses := t.Statuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+	for _, status := range statuses {
+		if res.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// replayableBody returns a function which returns a copy of the request
+// body. It returns a nil function and the body to send once when the body
+// cannot be replayed.
+func (t *RetryTransport) replayableBody(req *http.Request) (func() (io.ReadCloser, error), io.ReadCloser, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return func() (io.ReadCloser, error) { return req.Body, nil }, nil, nil
+	}
+	if req.GetBody != nil {
+		req.Body.Close()
+		return req.GetBody, nil, nil
+	}
+
+	max := int64(t.maxBufferSize())
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, max+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, nil, err
+	}
+	if int64(len(buf)) > max {
+		return nil, &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(buf), req.Body),
+			Closer: req.Body,
+		}, nil
+	}
+	req.Body.Close()
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}, nil, nil
+}
+
+// backoff returns the delay before the retry following attempt. Half of
+// the exponential delay is randomised to spread the retries of clients.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	min, max := t.MinBackoff, t.MaxBackoff
+	if min <= 0 {
+		min = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+
+	d := max
+	if attempt < 32 {
+		if e := min << uint(attempt-1); e > 0 && e < max {
+			d = e
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (t *RetryTransport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *RetryTransport) maxRetries() int {
+	if t.MaxRetries > 0 {
+		return t.MaxRetries
+	}
+	return 3
+}
+
+func (t *RetryTransport) maxRetryAfter() time.Duration {
+	if t.MaxRetryAfter > 0 {
+		return t.MaxRetryAfter
+	}
+	return time.Minute
+}
+
+func (t *RetryTransport) maxBufferSize() unit.Byte {
+	if t.MaxBufferSize > 0 {
+		return t.MaxBufferSize
+	}
+	return unit.MiB
+}
+
+// isIdempotent returns whether req can be sent several times
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// drain reads a bit of body before closing it, so the connection can be
+// reused
+func drain(body io.ReadCloser) {
+	io.CopyN(ioutil.Discard, body, 4096)
+	body.Close()
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
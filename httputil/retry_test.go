@@ -0,0 +1,251 @@
+package httputil_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/unit"
+)
+
+// TestRetryTransport ensures that failed requests are retried when it is safe
+func TestRetryTransport(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name     string
+		method   string
+		header   map[string]string
+		body     string
+		ctx      func() (context.Context, context.CancelFunc)
+		cap      time.Duration
+		failures int
+		status   int
+		attempts int64
+	}{
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			failures: 2,
+			status:   http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "put with body",
+			method:   http.MethodPut,
+			body:     "hello",
+			failures: 2,
+			status:   http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "post",
+			method:   http.MethodPost,
+			body:     "hello",
+			failures: 2,
+			status:   http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+		{
+			name:     "post with idempotency key",
+			method:   http.MethodPost,
+			header:   map[string]string{"Idempotency-Key": "abc"},
+			body:     "hello",
+			failures: 1,
+			status:   http.StatusOK,
+			attempts: 2,
+		},
+		{
+			name:     "exhausted",
+			method:   http.MethodGet,
+			header:   map[string]string{"X-Retry-After": "3600"},
+			failures: 10,
+			status:   http.StatusServiceUnavailable,
+			attempts: 4,
+		},
+		{
+			name:   "budget",
+			method: http.MethodGet,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return httputil.WithRetryBudget(context.Background(), 1), func() {}
+			},
+			failures: 10,
+			status:   http.StatusServiceUnavailable,
+			attempts: 2,
+		},
+		{
+			name:   "deadline",
+			method: http.MethodGet,
+			header: map[string]string{"X-Retry-After": "3600"},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			cap:      time.Hour,
+			failures: 10,
+			status:   http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+	}
+
+	for _, test := range table {
+		var attempts int64
+		failures := test.failures
+		expectBody := test.body
+		name := test.name
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt64(&attempts, 1)
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != expectBody {
+				t.Errorf("%s - expect body %q, but got %q", name, expectBody, body)
+			}
+			if int(n) <= failures {
+				if v := r.Header.Get("X-Retry-After"); v != "" {
+					w.Header().Set("Retry-After", v)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		maxRetryAfter := 2 * time.Millisecond
+		if test.cap > 0 {
+			maxRetryAfter = test.cap
+		}
+		var retries int
+		client := &http.Client{
+			Transport: &httputil.RetryTransport{
+				Transport:     srv.Client().Transport,
+				MinBackoff:    time.Millisecond,
+				MaxBackoff:    2 * time.Millisecond,
+				MaxRetryAfter: maxRetryAfter,
+				OnRetry: func(a httputil.RetryAttempt) {
+					retries++
+					if a.Attempt != retries {
+						t.Errorf("%s - expect attempt %d, but got %d", name, retries, a.Attempt)
+					}
+					if a.Response == nil || a.Response.StatusCode != http.StatusServiceUnavailable {
+						t.Errorf("%s - expect a failed response", name)
+					}
+				},
+			},
+		}
+
+		ctx, cancel := context.Background(), func() {}
+		if test.ctx != nil {
+			ctx, cancel = test.ctx()
+		}
+		var body io.Reader
+		if test.body != "" {
+			// Hide the type of the reader, so GetBody is not set
+			body = struct{ io.Reader }{strings.NewReader(test.body)}
+		}
+		req, err := http.NewRequest(test.method, srv.URL, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+		res, err := client.Do(req.WithContext(ctx))
+		cancel()
+		if err != nil {
+			t.Errorf("%s - unexpected error %s", test.name, err)
+			srv.Close()
+			continue
+		}
+		res.Body.Close()
+		srv.Close()
+
+		if test.status != res.StatusCode {
+			t.Errorf("%s - expect status %d, but got %d", test.name, test.status, res.StatusCode)
+		}
+		if test.attempts != attempts {
+			t.Errorf("%s - expect %d attempts, but got %d", test.name, test.attempts, attempts)
+		}
+		if int(test.attempts-1) != retries {
+			t.Errorf("%s - expect %d retries, but got %d", test.name, test.attempts-1, retries)
+		}
+	}
+}
+
+// TestRetryTransport_Errors ensures that transport errors are retried
+func TestRetryTransport_Errors(t *testing.T) {
+	t.Parallel()
+
+	errNetwork := errors.New("connection reset")
+	var attempts int
+	tr := &httputil.RetryTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errNetwork
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		MinBackoff: time.Millisecond,
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("expect a success after 3 attempts, but got %d after %d", res.StatusCode, attempts)
+	}
+
+	// Errors rejected by RetryError are returned straight away
+	attempts = 0
+	tr.RetryError = func(err error) bool { return err != errNetwork }
+	if _, err := tr.RoundTrip(req); err != errNetwork {
+		t.Errorf("expect error %s, but got %v", errNetwork, err)
+	}
+	if attempts != 1 {
+		t.Errorf("expect 1 attempt, but got %d", attempts)
+	}
+}
+
+// TestRetryTransport_LargeBody ensures that bodies too large to be buffered
+// are sent once
+func TestRetryTransport_LargeBody(t *testing.T) {
+	t.Parallel()
+
+	var attempts int
+	tr := &httputil.RetryTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			if string(body) != "0123456789" {
+				t.Errorf("expect the whole body to be sent, but got %q", body)
+			}
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}),
+		MinBackoff:    time.Millisecond,
+		MaxBufferSize: unit.Byte(4),
+	}
+	req := httptest.NewRequest(http.MethodPut, "http://example.com", struct{ io.Reader }{strings.NewReader("0123456789")})
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("expect a single attempt, but got %d", attempts)
+	}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
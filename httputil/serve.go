@@ -0,0 +1,179 @@
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deixis/pkg/utc"
+)
+
+// Content is a resource served with range support
+type Content struct {
+	// Reader reads the resource
+	Reader io.ReaderAt
+	// Size is the length of the resource
+	Size int64
+	// ContentType is the media type of the resource (application/octet-stream
+	// by default)
+	ContentType string
+	// ETag is the quoted entity tag of the resource (e.g. "xyz", W/"xyz")
+	ETag string
+	// LastModified is the time the resource was last modified (optional)
+	LastModified utc.UTC
+}
+
+// NewContent returns a Content which reads from rs. Its size is found by
+// seeking to the end of rs.
+func NewContent(rs io.ReadSeeker) (*Content, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return &Content{
+		Reader: &seekerReaderAt{rs: rs},
+		Size:   size,
+	}, nil
+}
+
+// ServeRange replies to the request with the content, honouring the Range
+// and If-Range headers.
+//
+// A single range is served with a 206 Partial Content, and several ranges
+// with a multipart/byteranges body. Unsatisfiable ranges are answered with a
+// 416 Range Not Satisfiable. Invalid Range headers, and Range headers with a
+// stale If-Range condition, are ignored and the whole content is served.
+func ServeRange(w http.ResponseWriter, r *http.Request, c *Content) {
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	if c.ETag != "" {
+		h.Set("ETag", c.ETag)
+	}
+	if !c.LastModified.IsZero() {
+		h.Set("Last-Modified", c.LastModified.Time().Format(http.TimeFormat))
+	}
+	contentType := c.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var ranges []HTTPRange
+	if s := r.Header.Get("Range"); s != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) && c.ifRange(r) {
+		var err error
+		ranges, err = ParseRange(s, c.Size)
+		switch err {
+		case nil:
+		case ErrUnsatisfiableRange:
+			FormatContentRange(h, HTTPRange{Start: -1, End: -1, Size: c.Size})
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		default:
+			ranges = nil
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.FormatInt(c.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			io.Copy(w, io.NewSectionReader(c.Reader, 0, c.Size))
+		}
+	case 1:
+		ran := ranges[0]
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.FormatInt(ran.Length(), 10))
+		FormatContentRange(h, ran)
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method != http.MethodHead {
+			io.Copy(w, ran.SectionReader(c.Reader))
+		}
+	default:
+		// Compute the length of the body by writing the parts without content
+		counter := &countingWriter{}
+		mw := multipart.NewWriter(counter)
+		for _, ran := range ranges {
+			mw.CreatePart(partHeader(ran, contentType))
+			counter.n += ran.Length()
+		}
+		mw.Close()
+
+		h.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		h.Set("Content-Length", strconv.FormatInt(counter.n, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		body := multipart.NewWriter(w)
+		body.SetBoundary(mw.Boundary())
+		for _, ran := range ranges {
+			part, err := body.CreatePart(partHeader(ran, contentType))
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(part, ran.SectionReader(c.Reader)); err != nil {
+				return
+			}
+		}
+		body.Close()
+	}
+}
+
+// ifRange returns whether the If-Range condition, if any, holds
+func (c *Content) ifRange(r *http.Request) bool {
+	v := strings.TrimSpace(r.Header.Get("If-Range"))
+	switch {
+	case v == "":
+		return true
+	case strings.HasPrefix(v, `"`), strings.HasPrefix(v, "W/"):
+		// Entity tags require a strong comparison
+		return c.ETag != "" && !strings.HasPrefix(c.ETag, "W/") && v == c.ETag
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil || c.LastModified.IsZero() {
+		return false
+	}
+	return c.LastModified.Floor(time.Second) == utc.Convert(t)
+}
+
+func partHeader(r HTTPRange, contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {contentType},
+		"Content-Range": {r.String()},
+	}
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// seekerReaderAt adapts an io.ReadSeeker to an io.ReaderAt
+type seekerReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekerReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek: %s", err)
+	}
+	return io.ReadFull(r.rs, p)
+}
@@ -0,0 +1,213 @@
+package httputil_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/utc"
+)
+
+// TestServeRange ensures that ranges are served according to the request headers
+func TestServeRange(t *testing.T) {
+	t.Parallel()
+
+	data := "0123456789abcdefghij"
+	modified := utc.MustParse("2020-05-01T10:00:00Z")
+
+	table := []struct {
+		method        string
+		header        map[string]string
+		status        int
+		body          string
+		contentRange  string
+		contentLength string
+	}{
+		{
+			status:        http.StatusOK,
+			body:          data,
+			contentLength: "20",
+		},
+		{
+			header:        map[string]string{"Range": "bytes=0-4"},
+			status:        http.StatusPartialContent,
+			body:          "01234",
+			contentRange:  "bytes 0-4/20",
+			contentLength: "5",
+		},
+		{
+			header:        map[string]string{"Range": "bytes=-3"},
+			status:        http.StatusPartialContent,
+			body:          "hij",
+			contentRange:  "bytes 17-19/20",
+			contentLength: "3",
+		},
+		{
+			method:        http.MethodHead,
+			header:        map[string]string{"Range": "bytes=10-"},
+			status:        http.StatusPartialContent,
+			contentRange:  "bytes 10-19/20",
+			contentLength: "10",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=20-30"},
+			status:       http.StatusRequestedRangeNotSatisfiable,
+			body:         httputil.ErrUnsatisfiableRange.Error() + "\n",
+			contentRange: "bytes */20",
+		},
+		{
+			header:        map[string]string{"Range": "lines=0-4"},
+			status:        http.StatusOK,
+			body:          data,
+			contentLength: "20",
+		},
+		{
+			header:        map[string]string{"Range": "bytes=0-4", "If-Range": `"v1"`},
+			status:        http.StatusPartialContent,
+			body:          "01234",
+			contentRange:  "bytes 0-4/20",
+			contentLength: "5",
+		},
+		{
+			header:        map[string]string{"Range": "bytes=0-4", "If-Range": `"v2"`},
+			status:        http.StatusOK,
+			body:          data,
+			contentLength: "20",
+		},
+		{
+			header:        map[string]string{"Range": "bytes=0-4", "If-Range": `W/"v1"`},
+			status:        http.StatusOK,
+			body:          data,
+			contentLength: "20",
+		},
+		{
+			header: map[string]string{
+				"Range":    "bytes=5-6",
+				"If-Range": modified.Time().Format(http.TimeFormat),
+			},
+			status:        http.StatusPartialContent,
+			body:          "56",
+			contentRange:  "bytes 5-6/20",
+			contentLength: "2",
+		},
+		{
+			header: map[string]string{
+				"Range":    "bytes=5-6",
+				"If-Range": modified.Add(-time.Hour).Time().Format(http.TimeFormat),
+			},
+			status:        http.StatusOK,
+			body:          data,
+			contentLength: "20",
+		},
+	}
+
+	for i, test := range table {
+		method := test.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		r := httptest.NewRequest(method, "/", nil)
+		for k, v := range test.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		httputil.ServeRange(w, r, &httputil.Content{
+			Reader:       strings.NewReader(data),
+			Size:         int64(len(data)),
+			ContentType:  "text/plain",
+			ETag:         `"v1"`,
+			LastModified: modified,
+		})
+
+		if test.status != w.Code {
+			t.Errorf("#%d - expect status %d, but got %d", i, test.status, w.Code)
+		}
+		if test.body != w.Body.String() {
+			t.Errorf("#%d - expect body %q, but got %q", i, test.body, w.Body.String())
+		}
+		if v := w.Header().Get("Content-Range"); test.contentRange != v {
+			t.Errorf("#%d - expect Content-Range %q, but got %q", i, test.contentRange, v)
+		}
+		if v := w.Header().Get("Content-Length"); test.contentLength != "" && test.contentLength != v {
+			t.Errorf("#%d - expect Content-Length %q, but got %q", i, test.contentLength, v)
+		}
+		if v := w.Header().Get("Accept-Ranges"); v != "bytes" {
+			t.Errorf("#%d - expect Accept-Ranges bytes, but got %q", i, v)
+		}
+	}
+}
+
+// TestServeRange_Multipart ensures that several ranges are served as
+// a multipart/byteranges body
+func TestServeRange_Multipart(t *testing.T) {
+	t.Parallel()
+
+	data := "0123456789abcdefghij"
+	content, err := httputil.NewContent(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content.ContentType = "text/plain"
+	if content.Size != int64(len(data)) {
+		t.Fatalf("expect size %d, but got %d", len(data), content.Size)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=0-1, 10-12, -2")
+	w := httptest.NewRecorder()
+	httputil.ServeRange(w, r, content)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expect status %d, but got %d", http.StatusPartialContent, w.Code)
+	}
+	if v := w.Header().Get("Content-Length"); v != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("expect Content-Length %d, but got %s", w.Body.Len(), v)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/byteranges" {
+		t.Fatalf("expect multipart/byteranges, but got %s", mediaType)
+	}
+
+	expect := []struct {
+		contentRange string
+		body         string
+	}{
+		{"bytes 0-1/20", "01"},
+		{"bytes 10-12/20", "abc"},
+		{"bytes 18-19/20", "ij"},
+	}
+	mr := multipart.NewReader(bytes.NewReader(w.Body.Bytes()), params["boundary"])
+	for i, e := range expect {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("#%d - %s", i, err)
+		}
+		if v := part.Header.Get("Content-Range"); e.contentRange != v {
+			t.Errorf("#%d - expect Content-Range %q, but got %q", i, e.contentRange, v)
+		}
+		if v := part.Header.Get("Content-Type"); v != "text/plain" {
+			t.Errorf("#%d - expect Content-Type text/plain, but got %q", i, v)
+		}
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.body != string(body) {
+			t.Errorf("#%d - expect body %q, but got %q", i, e.body, body)
+		}
+	}
+	if _, err := mr.NextPart(); err == nil {
+		t.Error("expect no more parts")
+	}
+}
@@ -0,0 +1,47 @@
+package httputil
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/deixis/pkg/unit"
+)
+
+// Throttle returns a middleware which limits the throughput of response
+// bodies. The throttle function picks the Throttle of each request (e.g. one
+// per tenant), and it may return nil to leave a response unthrottled.
+func Throttle(throttle func(r *http.Request) *unit.Throttle) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if t := throttle(r); t != nil {
+				w = ThrottleResponseWriter(w, r, t)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ThrottleResponseWriter returns a ResponseWriter whose body throughput is
+// limited by t. Writes are interrupted when the request context is done.
+func ThrottleResponseWriter(w http.ResponseWriter, r *http.Request, t *unit.Throttle) http.ResponseWriter {
+	return &throttledResponseWriter{
+		ResponseWriter: w,
+		w:              t.Writer(r.Context(), w),
+	}
+}
+
+type throttledResponseWriter struct {
+	http.ResponseWriter
+
+	w io.Writer
+}
+
+func (w *throttledResponseWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *throttledResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,50 @@
+package httputil_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/unit"
+)
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("a"), 3000)
+	limited := unit.NewThrottle(10*unit.ByteRate(unit.DecimalKB), unit.DecimalKB)
+	h := httputil.Throttle(func(r *http.Request) *unit.Throttle {
+		if r.URL.Query().Get("tenant") == "limited" {
+			return limited
+		}
+		return nil
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+
+	table := []struct {
+		url string
+		min time.Duration
+		max time.Duration
+	}{
+		{url: "/?tenant=other", min: 0, max: 100 * time.Millisecond},
+		{url: "/?tenant=limited", min: 150 * time.Millisecond, max: time.Second},
+	}
+
+	for i, test := range table {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", test.url, nil))
+		elapsed := time.Since(start)
+
+		if !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("#%d - expect to get the whole content", i)
+		}
+		if elapsed < test.min || elapsed > test.max {
+			t.Errorf("#%d - expect to take between %s and %s, but took %s", i, test.min, test.max, elapsed)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/deixis/pkg/unit"
+)
+
+// ErrUploadNotFound is returned when an upload does not exist
+var ErrUploadNotFound = errors.New("upload not found")
+
+// ErrUploadOffset is returned when a chunk does not start where the stored
+// data ends
+var ErrUploadOffset = errors.New("chunk does not match the upload offset")
+
+// ErrUploadSize is returned when a chunk declares a different upload size
+// than the previous ones
+var ErrUploadSize = errors.New("chunk does not match the upload size")
+
+// ErrUploadComplete is returned when data is sent to a complete upload
+var ErrUploadComplete = errors.New("upload already complete")
+
+// ErrInvalidUploadID is returned when an upload ID cannot be used by a store
+var ErrInvalidUploadID = errors.New("invalid upload ID")
+
+// UploadStatus is the state of an upload
+type UploadStatus struct {
+	// Offset is the number of bytes stored
+	Offset int64
+	// Size is the size declared by the chunks, or 0 until a chunk declares it
+	Size int64
+	// Complete is true once the upload has been finalised
+	Complete bool
+}
+
+// UploadStore persists the chunks of resumable uploads
+type UploadStore interface {
+	// Stat returns the status of the upload id. Unknown uploads have a zero
+	// status.
+	Stat(ctx context.Context, id string) (UploadStatus, error)
+	// Append stores the data read from r at offset, which must be equal to
+	// the upload offset. It returns the number of bytes stored, even when the
+	// reader fails.
+	//
+	// size is the upload size declared by the chunk, or UnknownSize. It is
+	// recorded by the first chunk declaring it, and the following chunks
+	// fail with ErrUploadSize when they declare another size.
+	Append(ctx context.Context, id string, offset, size int64, r io.Reader) (int64, error)
+	// Complete finalises the upload id once size bytes have been stored
+	Complete(ctx context.Context, id string, size int64) error
+}
+
+// UploadHandler receives uploads sent as a series of PUT requests carrying
+// a Content-Range header (e.g. bytes 0-1048575/4194304).
+//
+// Chunks must be sent in order, and declare the same size when they know
+// it. The size declared by a chunk applies to the following chunks which
+// do not know it (e.g. bytes 8-9/*). A chunk overlapping data already
+// stored is accepted and only its new bytes are stored, so clients can
+// safely retry.
+// Clients query the offset to resume from with an empty PUT carrying an
+// unsatisfied range (e.g. bytes */4194304), or with a HEAD request.
+//
+// Incomplete uploads are answered with a 308 and a Range header covering
+// the stored bytes (omitted when nothing is stored), and complete uploads
+// with a 201 Created.
+type UploadHandler struct {
+	// Store persists the uploads
+	Store UploadStore
+	// MaxSize is the maximum size of an upload (unlimited when 0)
+	MaxSize unit.Byte
+	// ID returns the upload ID of a request (the last element of the URL path
+	// by default)
+	ID func(r *http.Request) string
+	// OnComplete is called when an upload is finalised, instead of replying
+	// with a 201 Created (optional)
+	OnComplete func(w http.ResponseWriter, r *http.Request, id string, size int64)
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := h.id(r)
+	if id == "" {
+		http.Error(w, ErrInvalidUploadID.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		status, err := h.Store.Stat(r.Context(), id)
+		if err != nil {
+			h.fail(w, err)
+			return
+		}
+		h.writeStatus(w, status)
+		return
+	case http.MethodPut:
+	default:
+		w.Header().Set("Allow", "HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ran, err := ParseContentRange(r.Header.Get(contentRange))
+	if err == nil && ran.Unit != "" {
+		err = ErrInvalidFormat
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.tooLarge(ran) {
+		WriteTooLarge(w, h.MaxSize)
+		return
+	}
+
+	status, err := h.Store.Stat(r.Context(), id)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	// The size cannot change once declared
+	size := ran.Size
+	if size != UnknownSize && ((status.Size > 0 && size != status.Size) || size < status.Offset) {
+		setStored(w.Header(), status.Offset)
+		http.Error(w, ErrUploadSize.Error(), http.StatusConflict)
+		return
+	}
+	if size == UnknownSize && status.Size > 0 {
+		size = status.Size
+	}
+
+	// Offset query
+	if ran.Unsatisfied() {
+		if !status.Complete && size > 0 && status.Offset == size {
+			h.complete(w, r, id, size)
+			return
+		}
+		h.writeStatus(w, status)
+		return
+	}
+
+	if status.Complete {
+		h.writeStatus(w, status)
+		return
+	}
+	if size != UnknownSize && ran.End >= size {
+		http.Error(w, ErrInvalidRange.Error(), http.StatusBadRequest)
+		return
+	}
+	if ran.Start > status.Offset {
+		setStored(w.Header(), status.Offset)
+		http.Error(w, ErrUploadOffset.Error(), http.StatusConflict)
+		return
+	}
+	if r.ContentLength >= 0 && r.ContentLength != ran.Length() {
+		http.Error(w, "Content-Length does not match Content-Range", http.StatusBadRequest)
+		return
+	}
+
+	// Skip the bytes already stored, which happens when a chunk is retried
+	body := io.LimitReader(r.Body, ran.Length())
+	if skip := status.Offset - ran.Start; skip > 0 {
+		if _, err := io.CopyN(ioutil.Discard, body, skip); err != nil {
+			http.Error(w, "incomplete chunk", http.StatusBadRequest)
+			return
+		}
+	}
+	if ran.End >= status.Offset {
+		n, err := h.Store.Append(r.Context(), id, status.Offset, ran.Size, body)
+		status.Offset += n
+		if err != nil {
+			h.fail(w, err)
+			return
+		}
+		if status.Offset <= ran.End {
+			setStored(w.Header(), status.Offset)
+			http.Error(w, "incomplete chunk", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if size != UnknownSize && status.Offset == size {
+		h.complete(w, r, id, size)
+		return
+	}
+	h.writeStatus(w, status)
+}
+
+func (h *UploadHandler) id(r *http.Request) string {
+	if h.ID != nil {
+		return h.ID(r)
+	}
+	id := path.Base(r.URL.Path)
+	if id == "/" || id == "." {
+		return ""
+	}
+	return id
+}
+
+// tooLarge returns whether r exceeds the maximum upload size
+func (h *UploadHandler) tooLarge(r *HTTPRange) bool {
+	if h.MaxSize <= 0 {
+		return false
+	}
+	return unit.Byte(r.Size) > h.MaxSize || unit.Byte(r.End+1) > h.MaxSize
+}
+
+func (h *UploadHandler) complete(w http.ResponseWriter, r *http.Request, id string, size int64) {
+	if err := h.Store.Complete(r.Context(), id, size); err != nil {
+		h.fail(w, err)
+		return
+	}
+	if h.OnComplete != nil {
+		h.OnComplete(w, r, id, size)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// writeStatus replies with the status of an upload
+func (h *UploadHandler) writeStatus(w http.ResponseWriter, status UploadStatus) {
+	if status.Complete {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	setStored(w.Header(), status.Offset)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func (h *UploadHandler) fail(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrUploadOffset, ErrUploadSize, ErrUploadComplete:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrUploadNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrInvalidUploadID:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// setStored sets the Range header covering the first offset bytes, unless
+// nothing is stored
+func setStored(h http.Header, offset int64) {
+	if offset > 0 {
+		h.Set("Range", "bytes=0-"+strconv.FormatInt(offset-1, 10))
+	}
+}
@@ -0,0 +1,279 @@
+package httputil_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/httputil"
+	"github.com/deixis/pkg/unit"
+)
+
+// TestUploadHandler ensures that chunked uploads are validated, resumed and
+// finalised
+func TestUploadHandler(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		method       string
+		contentRange string
+		body         string
+		status       int
+		rangeHeader  string
+	}
+	table := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "in order",
+			steps: []step{
+				{contentRange: "bytes 0-3/10", body: "0123", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-3"},
+				{contentRange: "bytes 4-7/10", body: "4567", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-7"},
+				{contentRange: "bytes 8-9/10", body: "89", status: http.StatusCreated},
+				{method: http.MethodHead, status: http.StatusOK},
+				{contentRange: "bytes 8-9/10", body: "89", status: http.StatusOK},
+			},
+		},
+		{
+			name: "offset query",
+			steps: []step{
+				{contentRange: "bytes */10", status: http.StatusPermanentRedirect},
+				{method: http.MethodHead, status: http.StatusPermanentRedirect},
+				{contentRange: "bytes 0-5/*", body: "012345", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-5"},
+				{contentRange: "bytes */10", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-5"},
+				{method: http.MethodHead, status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-5"},
+				{contentRange: "bytes 6-9/10", body: "6789", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "retry",
+			steps: []step{
+				{contentRange: "bytes 0-5/10", body: "012345", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-5"},
+				{contentRange: "bytes 0-5/10", body: "012345", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-5"},
+				{contentRange: "bytes 4-9/10", body: "456789", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "finalise on query",
+			steps: []step{
+				{contentRange: "bytes 0-9/*", body: "0123456789", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-9"},
+				{contentRange: "bytes */10", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "gap",
+			steps: []step{
+				{contentRange: "bytes 0-3/10", body: "0123", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-3"},
+				{contentRange: "bytes 5-9/10", body: "56789", status: http.StatusConflict, rangeHeader: "bytes=0-3"},
+				{contentRange: "bytes 4-9/10", body: "456789", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "invalid",
+			steps: []step{
+				{status: http.StatusBadRequest},
+				{contentRange: "bytes 0-3", body: "0123", status: http.StatusBadRequest},
+				{contentRange: "bytes 0-10/10", body: "0123456789a", status: http.StatusBadRequest},
+				{contentRange: "bytes 0-3/10", body: "012", status: http.StatusBadRequest},
+				{method: http.MethodDelete, status: http.StatusMethodNotAllowed},
+				{contentRange: "bytes 0-9/10", body: "0123456789", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "size mismatch",
+			steps: []step{
+				{contentRange: "bytes 0-3/10", body: "0123", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-3"},
+				{contentRange: "bytes 4-7/8", body: "4567", status: http.StatusConflict, rangeHeader: "bytes=0-3"},
+				{contentRange: "bytes 4-7/*", body: "4567", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-7"},
+				{contentRange: "bytes */12", status: http.StatusConflict, rangeHeader: "bytes=0-7"},
+				{contentRange: "bytes */6", status: http.StatusConflict, rangeHeader: "bytes=0-7"},
+				{contentRange: "bytes 8-11/*", body: "89ab", status: http.StatusBadRequest},
+				{method: http.MethodHead, status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-7"},
+				{contentRange: "bytes 8-9/*", body: "89", status: http.StatusCreated},
+			},
+		},
+		{
+			name: "too large",
+			steps: []step{
+				{contentRange: "bytes 0-3/100", body: "0123", status: http.StatusRequestEntityTooLarge},
+				{contentRange: "bytes 0-19/*", body: "01234567890123456789", status: http.StatusRequestEntityTooLarge},
+				{contentRange: "bytes 0-9/*", body: "0123456789", status: http.StatusPermanentRedirect, rangeHeader: "bytes=0-9"},
+				{contentRange: "bytes */10", status: http.StatusCreated},
+			},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := httputil.NewFileUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memStore := httputil.NewMemoryUploadStore()
+
+	stores := []struct {
+		name    string
+		store   httputil.UploadStore
+		content func(id string) (string, bool)
+	}{
+		{
+			name:  "memory",
+			store: memStore,
+			content: func(id string) (string, bool) {
+				b, ok := memStore.Bytes(id)
+				return string(b), ok
+			},
+		},
+		{
+			name:  "file",
+			store: fileStore,
+			content: func(id string) (string, bool) {
+				name, err := fileStore.Path(id)
+				if err != nil {
+					return "", false
+				}
+				b, err := ioutil.ReadFile(name)
+				return string(b), err == nil
+			},
+		},
+	}
+
+	for _, s := range stores {
+		h := &httputil.UploadHandler{
+			Store:   s.store,
+			MaxSize: unit.Byte(16),
+		}
+		for _, test := range table {
+			id := strings.Replace(test.name, " ", "-", -1)
+			for i, step := range test.steps {
+				method := step.method
+				if method == "" {
+					method = http.MethodPut
+				}
+				r := httptest.NewRequest(method, "/uploads/"+id, strings.NewReader(step.body))
+				if step.contentRange != "" {
+					r.Header.Set("Content-Range", step.contentRange)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+
+				if step.status != w.Code {
+					t.Errorf("%s/%s #%d - expect status %d, but got %d (%s)",
+						s.name, test.name, i, step.status, w.Code, w.Body.String())
+				}
+				if v := w.Header().Get("Range"); step.rangeHeader != v {
+					t.Errorf("%s/%s #%d - expect Range %q, but got %q",
+						s.name, test.name, i, step.rangeHeader, v)
+				}
+			}
+
+			content, ok := s.content(id)
+			if !ok {
+				t.Errorf("%s/%s - expect upload to be complete", s.name, test.name)
+			} else if content != "0123456789" {
+				t.Errorf("%s/%s - expect content %q, but got %q", s.name, test.name, "0123456789", content)
+			}
+		}
+	}
+}
+
+// TestFileUploadStore_InvalidID ensures that upload IDs cannot escape the
+// store directory
+func TestFileUploadStore_InvalidID(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := httputil.NewFileUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", ".", "..", "../x", "a/b", `a\b`, "x.part", "x.size"} {
+		if _, err := store.Path(id); err != httputil.ErrInvalidUploadID {
+			t.Errorf("%q - expect error %s, but got %v", id, httputil.ErrInvalidUploadID, err)
+		}
+	}
+}
+
+// TestUploadHandler_Stalled ensures that a stalled chunk does not hold up
+// the other uploads
+func TestUploadHandler_Stalled(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := httputil.NewFileUploadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]httputil.UploadStore{
+		"memory": httputil.NewMemoryUploadStore(),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		h := &httputil.UploadHandler{Store: store}
+
+		// The body of the first chunk never arrives
+		pr, pw := io.Pipe()
+		reading := make(chan struct{})
+		stalled := make(chan struct{})
+		go func() {
+			defer close(stalled)
+			body := &notifyReader{Reader: pr, c: reading}
+			r := httptest.NewRequest(http.MethodPut, "/uploads/a", body)
+			r.Header.Set("Content-Range", "bytes 0-3/4")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+		<-reading
+
+		done := make(chan int)
+		go func() {
+			r := httptest.NewRequest(http.MethodPut, "/uploads/b", strings.NewReader("0123"))
+			r.Header.Set("Content-Range", "bytes 0-3/4")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			done <- w.Code
+		}()
+		select {
+		case code := <-done:
+			if code != http.StatusCreated {
+				t.Errorf("%s - expect status %d, but got %d", name, http.StatusCreated, code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s - expect upload b not to wait for upload a", name)
+		}
+
+		pw.CloseWithError(io.ErrUnexpectedEOF)
+		<-stalled
+	}
+}
+
+// notifyReader closes c on the first read
+type notifyReader struct {
+	io.Reader
+	c    chan struct{}
+	once sync.Once
+}
+
+func (r *notifyReader) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.c) })
+	return r.Reader.Read(p)
+}
@@ -0,0 +1,310 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryUploadStore is an UploadStore which keeps uploads in memory
+type MemoryUploadStore struct {
+	mu      sync.Mutex
+	uploads map[string]*memoryUpload
+}
+
+type memoryUpload struct {
+	data     bytes.Buffer
+	size     int64
+	complete bool
+}
+
+// NewMemoryUploadStore returns an empty MemoryUploadStore
+func NewMemoryUploadStore() *MemoryUploadStore {
+	return &MemoryUploadStore{
+		uploads: map[string]*memoryUpload{},
+	}
+}
+
+// Stat returns the status of the upload id
+func (s *MemoryUploadStore) Stat(ctx context.Context, id string) (UploadStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return UploadStatus{}, nil
+	}
+	return UploadStatus{Offset: int64(u.data.Len()), Size: u.size, Complete: u.complete}, nil
+}
+
+// Append stores the data read from r at the end of the upload id
+func (s *MemoryUploadStore) Append(ctx context.Context, id string, offset, size int64, r io.Reader) (int64, error) {
+	// The chunk is read before locking the store, so a slow client does not
+	// hold up the other uploads
+	var chunk bytes.Buffer
+	_, rerr := chunk.ReadFrom(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		u = &memoryUpload{}
+		s.uploads[id] = u
+	}
+	if u.complete {
+		return 0, ErrUploadComplete
+	}
+	if int64(u.data.Len()) != offset {
+		return 0, ErrUploadOffset
+	}
+	if size != UnknownSize {
+		if u.size > 0 && u.size != size {
+			return 0, ErrUploadSize
+		}
+		u.size = size
+	}
+	n, _ := u.data.Write(chunk.Bytes())
+	return int64(n), rerr
+}
+
+// Complete finalises the upload id
+func (s *MemoryUploadStore) Complete(ctx context.Context, id string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		if size != 0 {
+			return ErrUploadNotFound
+		}
+		u = &memoryUpload{}
+		s.uploads[id] = u
+	}
+	if int64(u.data.Len()) != size {
+		return ErrUploadOffset
+	}
+	if u.size > 0 && u.size != size {
+		return ErrUploadSize
+	}
+	u.size = size
+	u.complete = true
+	return nil
+}
+
+// Bytes returns the content of the complete upload id
+func (s *MemoryUploadStore) Bytes(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok || !u.complete {
+		return nil, false
+	}
+	return append([]byte(nil), u.data.Bytes()...), true
+}
+
+// FileUploadStore is an UploadStore which keeps uploads in a directory.
+//
+// Incomplete uploads are stored in <id>.part files, which are renamed to
+// <id> once complete. Their declared size is kept in <id>.size files.
+type FileUploadStore struct {
+	dir   string
+	locks uploadLocks
+}
+
+// NewFileUploadStore returns a FileUploadStore writing to dir, which is
+// created if it does not exist
+func NewFileUploadStore(dir string) (*FileUploadStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileUploadStore{dir: dir}, nil
+}
+
+// Path returns the path of the complete upload id
+func (s *FileUploadStore) Path(id string) (string, error) {
+	if !validUploadID(id) {
+		return "", ErrInvalidUploadID
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+// Stat returns the status of the upload id
+func (s *FileUploadStore) Stat(ctx context.Context, id string) (UploadStatus, error) {
+	return s.stat(id)
+}
+
+// stat does not need to lock the upload, because the part file is checked
+// before the complete one it is renamed to
+func (s *FileUploadStore) stat(id string) (UploadStatus, error) {
+	name, err := s.Path(id)
+	if err != nil {
+		return UploadStatus{}, err
+	}
+	if fi, err := os.Stat(name + ".part"); err == nil {
+		size, err := readUploadSize(name + ".size")
+		if err != nil {
+			return UploadStatus{}, err
+		}
+		return UploadStatus{Offset: fi.Size(), Size: size}, nil
+	} else if !os.IsNotExist(err) {
+		return UploadStatus{}, err
+	}
+	fi, err := os.Stat(name)
+	switch {
+	case os.IsNotExist(err):
+		return UploadStatus{}, nil
+	case err != nil:
+		return UploadStatus{}, err
+	}
+	return UploadStatus{Offset: fi.Size(), Size: fi.Size(), Complete: true}, nil
+}
+
+// Append stores the data read from r at the end of the upload id
+func (s *FileUploadStore) Append(ctx context.Context, id string, offset, size int64, r io.Reader) (int64, error) {
+	if !validUploadID(id) {
+		return 0, ErrInvalidUploadID
+	}
+	defer s.locks.lock(id)()
+
+	status, err := s.stat(id)
+	if err != nil {
+		return 0, err
+	}
+	if status.Complete {
+		return 0, ErrUploadComplete
+	}
+	if status.Offset != offset {
+		return 0, ErrUploadOffset
+	}
+
+	name, _ := s.Path(id)
+	if size != UnknownSize {
+		switch status.Size {
+		case size:
+		case 0:
+			// The size is written before the part file is created, so it is
+			// known by stat as soon as the upload is
+			if err := ioutil.WriteFile(name+".size", []byte(strconv.FormatInt(size, 10)), 0644); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, ErrUploadSize
+		}
+	}
+	f, err := os.OpenFile(name+".part", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Complete finalises the upload id
+func (s *FileUploadStore) Complete(ctx context.Context, id string, size int64) error {
+	if !validUploadID(id) {
+		return ErrInvalidUploadID
+	}
+	defer s.locks.lock(id)()
+
+	status, err := s.stat(id)
+	if err != nil {
+		return err
+	}
+	if status.Complete {
+		return ErrUploadComplete
+	}
+	if status.Offset != size {
+		return ErrUploadOffset
+	}
+	if status.Size > 0 && status.Size != size {
+		return ErrUploadSize
+	}
+
+	name, _ := s.Path(id)
+	if size == 0 {
+		f, err := os.OpenFile(name+".part", os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	if err := os.Rename(name+".part", name); err != nil {
+		return err
+	}
+	if err := os.Remove(name + ".size"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readUploadSize reads the declared size of an upload, which is 0 when it
+// has not been declared yet
+func readUploadSize(name string) (int64, error) {
+	b, err := ioutil.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case len(b) == 0:
+		// Being written
+		return 0, nil
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+// uploadLocks serialises the writes to each upload, without holding up the
+// other uploads
+type uploadLocks struct {
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the upload id, and returns the function unlocking it
+func (l *uploadLocks) lock(id string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*uploadLock{}
+	}
+	u, ok := l.locks[id]
+	if !ok {
+		u = &uploadLock{}
+		l.locks[id] = u
+	}
+	u.refs++
+	l.mu.Unlock()
+
+	u.Lock()
+	return func() {
+		u.Unlock()
+
+		l.mu.Lock()
+		if u.refs--; u.refs == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// validUploadID returns whether id can be used as a file name
+func validUploadID(id string) bool {
+	return id != "" && id != "." && id != ".." &&
+		!strings.ContainsAny(id, `/\`+"\x00") &&
+		!strings.HasSuffix(id, ".part") && !strings.HasSuffix(id, ".size")
+}
@@ -0,0 +1,43 @@
+package lang
+
+import (
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/de_CH"
+	"github.com/go-playground/locales/de_DE"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/en_GB"
+	"github.com/go-playground/locales/en_US"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/fr_CH"
+	"github.com/go-playground/locales/fr_FR"
+	"github.com/go-playground/locales/it"
+	"github.com/go-playground/locales/it_CH"
+	"github.com/go-playground/locales/it_IT"
+)
+
+var localeMapper = map[string]locales.Translator{
+	"de":    de.New(),
+	"de-CH": de_CH.New(),
+	"de-DE": de_DE.New(),
+	"en":    en.New(),
+	"en-GB": en_GB.New(),
+	"en-US": en_US.New(),
+	"fr":    fr.New(),
+	"fr-CH": fr_CH.New(),
+	"fr-FR": fr_FR.New(),
+	"it":    it.New(),
+	"it-CH": it_CH.New(),
+	"it-IT": it_IT.New(),
+}
+
+var fallback = localeMapper["en"]
+
+// Translator returns the CLDR locale of the language tag, which formats
+// numbers, dates, and plurals. English is returned for unsupported tags.
+func (t Tag) Translator() locales.Translator {
+	if l, ok := localeMapper[t.String()]; ok {
+		return l
+	}
+	return fallback
+}
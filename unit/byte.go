@@ -1,12 +1,9 @@
 package unit
 
 import (
-	"fmt"
 	"math"
 	"strconv"
 	"strings"
-
-	"github.com/deixis/errors"
 )
 
 // Byte represents a byte size
@@ -14,73 +11,199 @@ type Byte float64
 
 const (
 	_ = iota
-	// KB means kilobyte
+	// KB means kilobyte (legacy convention, 1024 bytes)
 	KB Byte = 1 << (10 * iota)
-	// MB means megabyte
+	// MB means megabyte (legacy convention, 1024 KB)
 	MB
-	// GB means gigabyte
+	// GB means gigabyte (legacy convention, 1024 MB)
 	GB
-	// TB means terabyte
+	// TB means terabyte (legacy convention, 1024 GB)
 	TB
 )
 
-// ParseByte parses the string and return a Byte struct
-//
-// e.g. 3.3 kB, 3kB, 7 GB, 9 TB, -1 TB
-func ParseByte(s string) (Byte, error) {
-	s = strings.TrimSpace(s)
-	if len(s) < 3 {
-		return 0, fmt.Errorf("invalid format")
+const (
+	_ = iota
+	// KiB means kibibyte (IEC, 1024 bytes)
+	KiB Byte = 1 << (10 * iota)
+	// MiB means mebibyte (IEC, 1024 KiB)
+	MiB
+	// GiB means gibibyte (IEC, 1024 MiB)
+	GiB
+	// TiB means tebibyte (IEC, 1024 GiB)
+	TiB
+	// PiB means pebibyte (IEC, 1024 TiB)
+	PiB
+	// EiB means exbibyte (IEC, 1024 PiB)
+	EiB
+)
+
+const (
+	// DecimalKB means kilobyte (SI, 1000 bytes)
+	DecimalKB Byte = 1e3
+	// DecimalMB means megabyte (SI, 1000 kB)
+	DecimalMB Byte = 1e6
+	// DecimalGB means gigabyte (SI, 1000 MB)
+	DecimalGB Byte = 1e9
+	// DecimalTB means terabyte (SI, 1000 GB)
+	DecimalTB Byte = 1e12
+	// DecimalPB means petabyte (SI, 1000 TB)
+	DecimalPB Byte = 1e15
+	// DecimalEB means exabyte (SI, 1000 PB)
+	DecimalEB Byte = 1e18
+)
+
+// DisplayDigits is the number of significant digits used by Byte.Display
+const DisplayDigits = 3
+
+// System is a convention used to name multiples of a byte
+type System int
+
+const (
+	// Legacy is the convention historically used by this package. It names
+	// binary multiples (1024) with decimal symbols (kB, MB, GB, TB).
+	//
+	// It is the default convention, so values persisted by earlier versions
+	// can still be decoded.
+	Legacy System = iota
+	// IEC names binary multiples (1024) with binary symbols (KiB, MiB, ...)
+	IEC
+	// SI names decimal multiples (1000) with decimal symbols (kB, MB, ...)
+	SI
+)
+
+// scale is a named multiple of a byte
+type scale struct {
+	symbol string
+	mag    Byte
+}
+
+// Scales are ordered from the largest to the smallest
+var (
+	legacyScales = []scale{
+		{"TB", TB}, {"GB", GB}, {"MB", MB}, {"kB", KB},
+	}
+	iecScales = []scale{
+		{"EiB", EiB}, {"PiB", PiB}, {"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB},
 	}
+	siScales = []scale{
+		{"EB", DecimalEB}, {"PB", DecimalPB}, {"TB", DecimalTB}, {"GB", DecimalGB}, {"MB", DecimalMB}, {"kB", DecimalKB},
+	}
+)
 
-	// Either we have a space in between (or not)
-	res := strings.Split(s, " ")
-	var n, scale string
-	if len(res) == 2 {
-		n = res[0]
-		scale = res[1]
-	} else {
-		n = s[0 : len(s)-2]
-		scale = s[len(s)-2:]
+func (s System) String() string {
+	switch s {
+	case Legacy:
+		return "legacy"
+	case IEC:
+		return "IEC"
+	case SI:
+		return "SI"
 	}
+	return "System(" + strconv.Itoa(int(s)) + ")"
+}
 
-	var mag Byte
-	switch scale {
-	case "B":
-		mag = 1
-	case "kB":
-		mag = KB
-	case "MB":
-		mag = MB
-	case "GB":
-		mag = GB
-	case "TB":
-		mag = TB
-	default:
-		return 0, fmt.Errorf("unknown scale %s", scale)
+// scales returns the multiples used to format a value in the system s
+func (s System) scales() []scale {
+	switch s {
+	case IEC:
+		return iecScales
+	case SI:
+		return siScales
 	}
+	return legacyScales
+}
+
+// magnitude returns the multiple named by symbol in the system s (see
+// System.Dimension)
+func (s System) magnitude(symbol string) (Byte, bool) {
+	u, ok := s.dimension().lookup(symbol)
+	return Byte(u.Factor), ok
+}
+
+// Parse parses the string s according to the system and returns a Byte
+//
+// e.g. 3.3 kB, 3kB, 7 GB, 9 TB, -1 TB, 4 GiB
+func (s System) Parse(str string) (Byte, error) {
+	p := ByteParser{System: s}
+	return p.Parse(str)
+}
+
+// Format returns the canonical representation of b in the system s.
+//
+// It uses the largest multiple for which the value is at least 1 and which
+// represents b exactly, so s.Parse(s.Format(b)) == b for all finite values.
+func (s System) Format(b Byte) string {
+	return s.dimension().Format(float64(b))
+}
+
+// Display returns a human-readable representation of b in the system s,
+// rounded to the given number of significant digits (e.g. 1.0009765625 MB
+// is displayed as 1 MB with 3 digits).
+//
+// Unlike Format, the result is not meant to be parsed back, since it may
+// lose precision. Values so close to the largest float64 that rounding them
+// up would overflow are truncated instead.
+func (s System) Display(b Byte, digits int) string {
+	x, symbol := s.reduce(b)
+	mag, _ := s.magnitude(symbol)
+	n := formatSignificant(x, digits)
 
 	v, err := strconv.ParseFloat(n, 64)
-	if err != nil {
-		return 0, errors.Wrap(err, "invalid number")
+	switch {
+	case err != nil:
+	case math.IsInf(float64(Byte(v)*mag), 0):
+		n = formatSignificant(truncSignificant(x, digits), digits)
+	default:
+		// Rounding may reach the next multiple (e.g. 999.96 kB to 1000 kB)
+		if x2, symbol2 := s.reduce(Byte(v) * mag); symbol2 != symbol {
+			n, symbol = formatSignificant(x2, digits), symbol2
+		}
 	}
+	return n + " " + symbol
+}
 
-	return Byte(v) * mag, nil
+// truncSignificant truncates x to the given number of significant digits
+func truncSignificant(x float64, digits int) float64 {
+	if digits <= 0 || x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return x
+	}
+	p := math.Pow(10, math.Floor(math.Log10(math.Abs(x)))-float64(digits-1))
+	return math.Trunc(x/p) * p
 }
 
-func (b Byte) String() string {
-	switch {
-	case math.Abs(float64(b/TB)) >= 1:
-		return strconv.FormatFloat(float64(b/TB), 'f', -1, 64) + " TB"
-	case math.Abs(float64(b/GB)) >= 1:
-		return strconv.FormatFloat(float64(b/GB), 'f', -1, 64) + " GB"
-	case math.Abs(float64(b/MB)) >= 1:
-		return strconv.FormatFloat(float64(b/MB), 'f', -1, 64) + " MB"
-	case math.Abs(float64(b/KB)) >= 1:
-		return strconv.FormatFloat(float64(b/KB), 'f', -1, 64) + " kB"
+// reduce returns the value of b in the largest multiple for which the value
+// is at least 1, along with the symbol of that multiple.
+func (s System) reduce(b Byte) (float64, string) {
+	for _, sc := range s.scales() {
+		if math.Abs(float64(b/sc.mag)) >= 1 {
+			return float64(b / sc.mag), sc.symbol
+		}
 	}
+	return float64(b), "B"
+}
 
-	return strconv.FormatFloat(float64(b), 'f', -1, 64) + " B"
+// ParseByte parses the string and return a Byte struct.
+// It uses the Legacy system, so "kB" means 1024 bytes.
+//
+// e.g. 3.3 kB, 3kB, 7 GB, 9 TB, -1 TB, 4 GiB
+func ParseByte(s string) (Byte, error) {
+	return Legacy.Parse(s)
+}
+
+// String returns the canonical representation of b in the Legacy system
+func (b Byte) String() string {
+	return Legacy.Format(b)
+}
+
+// Display returns a human-readable representation of b in the Legacy system,
+// rounded to DisplayDigits significant digits.
+func (b Byte) Display() string {
+	return Legacy.Display(b, DisplayDigits)
+}
+
+// Format returns the canonical representation of b in the given system
+func (b Byte) Format(s System) string {
+	return s.Format(b)
 }
 
 // Gt returns whether the value b is greater than v
@@ -99,8 +222,22 @@ func (b Byte) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
+// It accepts either a quoted byte size (e.g. "1 MB"), or a plain number of
+// bytes (e.g. 1048576).
 func (b *Byte) UnmarshalJSON(data []byte) error {
-	r, err := ParseByte(strings.Trim(string(data), "\""))
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	var r Byte
+	var err error
+	if strings.HasPrefix(s, "\"") {
+		r, err = ParseByte(strings.Trim(s, "\""))
+	} else {
+		p := ByteParser{DefaultUnit: 1}
+		r, err = p.Parse(s)
+	}
 	if err != nil {
 		return err
 	}
@@ -1,7 +1,12 @@
 package unit_test
 
 import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/quick"
 
 	"github.com/deixis/pkg/unit"
 )
@@ -88,3 +93,187 @@ func TestByteStringFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestByteSystemParse(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		sys    unit.System
+		in     string
+		expect unit.Byte
+		err    bool
+	}{
+		{sys: unit.Legacy, in: "3 kB", expect: 3072},
+		{sys: unit.Legacy, in: "3 KiB", expect: 3072},
+		{sys: unit.Legacy, in: "3KiB", expect: 3072},
+		{sys: unit.Legacy, in: "2 EiB", expect: unit.EiB * 2},
+		{sys: unit.Legacy, in: "2 PB", err: true},
+		{sys: unit.SI, in: "3 kB", expect: 3000},
+		{sys: unit.SI, in: "3.3kB", expect: 3300},
+		{sys: unit.SI, in: "5 MB", expect: 5e6},
+		{sys: unit.SI, in: "1 EB", expect: 1e18},
+		{sys: unit.SI, in: "1 MiB", expect: 1048576},
+		{sys: unit.IEC, in: "1.5 GiB", expect: unit.GiB * 1.5},
+		{sys: unit.IEC, in: "1 PiB", expect: 1 << 50},
+		{sys: unit.IEC, in: "1 kB", expect: 1000},
+		{sys: unit.IEC, in: "1 XiB", err: true},
+		{sys: unit.IEC, in: "iB", err: true},
+	}
+
+	for i, test := range table {
+		res, err := test.sys.Parse(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %s", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+	}
+}
+
+func TestByteSystemFormat(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		sys    unit.System
+		in     unit.Byte
+		expect string
+	}{
+		{sys: unit.Legacy, in: unit.KiB * 3, expect: "3 kB"},
+		{sys: unit.Legacy, in: unit.PiB, expect: "1024 TB"},
+		{sys: unit.IEC, in: unit.KiB * 3, expect: "3 KiB"},
+		{sys: unit.IEC, in: unit.MiB * 1.5, expect: "1.5 MiB"},
+		{sys: unit.IEC, in: unit.EiB, expect: "1 EiB"},
+		{sys: unit.IEC, in: -unit.GiB, expect: "-1 GiB"},
+		{sys: unit.IEC, in: 1000, expect: "1000 B"},
+		{sys: unit.SI, in: 1000, expect: "1 kB"},
+		{sys: unit.SI, in: 3300, expect: "3.3 kB"},
+		{sys: unit.SI, in: unit.KiB, expect: "1.024 kB"},
+		{sys: unit.SI, in: 2e15, expect: "2 PB"},
+		{sys: unit.SI, in: 0, expect: "0 B"},
+	}
+
+	for i, test := range table {
+		res := test.in.Format(test.sys)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, res)
+		}
+	}
+}
+
+func TestByteDisplay(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		sys    unit.System
+		in     unit.Byte
+		digits int
+		expect string
+	}{
+		{sys: unit.Legacy, in: unit.MB + unit.KB, digits: 3, expect: "1 MB"},
+		{sys: unit.Legacy, in: unit.KB * 3.3, digits: 3, expect: "3.3 kB"},
+		{sys: unit.Legacy, in: unit.KB * 1023.9, digits: 3, expect: "1020 kB"},
+		{sys: unit.Legacy, in: unit.KB * 1023.9, digits: 4, expect: "1 MB"},
+		{sys: unit.Legacy, in: unit.KB * 1023.9, digits: 2, expect: "1000 kB"},
+		{sys: unit.SI, in: 999960, digits: 3, expect: "1 MB"},
+		{sys: unit.SI, in: 123456789, digits: 3, expect: "123 MB"},
+		{sys: unit.SI, in: 123456789, digits: 5, expect: "123.46 MB"},
+		{sys: unit.IEC, in: -unit.GiB * 1.5, digits: 1, expect: "-2 GiB"},
+		{sys: unit.IEC, in: 0, digits: 3, expect: "0 B"},
+		{sys: unit.IEC, in: 0.123456, digits: 2, expect: "0.12 B"},
+		{sys: unit.SI, in: -1.6945131424830253e+308, digits: 1, expect: "-1" + strings.Repeat("0", 290) + " EB"},
+		{sys: unit.SI, in: math.MaxFloat64, digits: 3, expect: "179" + strings.Repeat("0", 288) + " EB"},
+	}
+
+	for i, test := range table {
+		res := test.sys.Display(test.in, test.digits)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, res)
+		}
+	}
+
+	if got := (unit.MB + unit.KB).Display(); got != "1 MB" {
+		t.Errorf("expect to get 1 MB, but got %s", got)
+	}
+}
+
+// TestByteCanonicalRoundTrip ensures that formatting is lossless
+func TestByteCanonicalRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, sys := range []unit.System{unit.Legacy, unit.IEC, unit.SI} {
+		sys := sys
+		f := func(b unit.Byte) bool {
+			res, err := sys.Parse(sys.Format(b))
+			return err == nil && res == b
+		}
+		if err := quick.Check(f, &quick.Config{MaxCount: 5000, Values: byteValues}); err != nil {
+			t.Errorf("%s - %s", sys, err)
+		}
+	}
+}
+
+// TestByteDisplayPrecision ensures that displayed values are bounded
+// by the number of significant digits, and close to the actual value
+func TestByteDisplayPrecision(t *testing.T) {
+	t.Parallel()
+
+	for _, sys := range []unit.System{unit.Legacy, unit.IEC, unit.SI} {
+		for digits := 1; digits <= 6; digits++ {
+			sys, digits := sys, digits
+			f := func(b unit.Byte) bool {
+				s := sys.Display(b, digits)
+				res, err := sys.Parse(s)
+				if err != nil {
+					return false
+				}
+				if significantDigits(s) > digits {
+					return false
+				}
+				// Rounding to n significant digits is within 1/2 unit of the last digit
+				return math.Abs(float64(res-b)) <= math.Abs(float64(b))*0.5*math.Pow(10, float64(1-digits))*(1+1e-9)
+			}
+			if err := quick.Check(f, &quick.Config{MaxCount: 1000, Values: byteValues}); err != nil {
+				t.Errorf("%s/%d - %s", sys, digits, err)
+			}
+		}
+	}
+}
+
+// byteValues generates finite byte sizes across all magnitudes, except
+// subnormal numbers, whose precision is too low to be displayed, and numbers
+// close to the largest float64, which are truncated by Display
+func byteValues(values []reflect.Value, r *rand.Rand) {
+	var b float64
+	switch r.Intn(4) {
+	case 0:
+		b = float64(r.Int63n(1 << 40))
+	case 1:
+		b = float64(r.Int63())
+	case 2:
+		b = r.NormFloat64() * math.Pow(10, float64(r.Intn(21)))
+	default:
+		for b = math.NaN(); math.IsNaN(b) || math.IsInf(b, 0) || math.Abs(b) < 0x1p-1022 || math.Abs(b) > math.MaxFloat64/2; {
+			b = math.Float64frombits(r.Uint64())
+		}
+	}
+	values[0] = reflect.ValueOf(unit.Byte(b))
+}
+
+// significantDigits returns the number of significant digits of the number
+// at the beginning of s, excluding trailing zeros of integers
+func significantDigits(s string) int {
+	n := strings.TrimLeft(strings.Fields(s)[0], "-")
+	if strings.Contains(n, ".") {
+		n = strings.TrimLeft(strings.Replace(n, ".", "", 1), "0")
+		return len(n)
+	}
+	if strings.Trim(n, "0123456789") != "" {
+		return math.MaxInt32
+	}
+	return len(strings.TrimRight(n, "0"))
+}
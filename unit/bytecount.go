@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/deixis/errors"
+)
+
+// ByteCount represents an exact number of bytes
+//
+// Unlike Byte, it never holds a fractional number of bytes and it does not
+// lose precision above 2^53, which makes it suitable for keys and accounting.
+type ByteCount int64
+
+// ErrOverflow is returned when a value cannot be represented by a ByteCount
+var ErrOverflow = errors.New("byte count overflows int64")
+
+// ParseByteCount parses the string and returns an exact ByteCount.
+// It uses the Legacy system, so "kB" means 1024 bytes.
+//
+// Fractional byte counts are rounded to the nearest integer, with halves
+// rounded away from zero (e.g. 3.3 kB is 3379 bytes).
+func ParseByteCount(s string) (ByteCount, error) {
+	return Legacy.ParseCount(s)
+}
+
+// ParseCount parses the string s according to the system and returns an
+// exact ByteCount. Rounding follows the rules of ParseByteCount.
+func (s System) ParseCount(str string) (ByteCount, error) {
+	p := ByteParser{System: s}
+	return p.ParseCount(str)
+}
+
+// FormatCount returns the exact string representation of c in the system s.
+// It uses the largest multiple for which the value is at least 1.
+func (s System) FormatCount(c ByteCount) string {
+	for _, sc := range s.scales() {
+		mag := ByteCount(sc.mag)
+		if c >= mag || c <= -mag {
+			return formatRat(big.NewRat(int64(c), int64(mag))) + " " + sc.symbol
+		}
+	}
+	return fmt.Sprintf("%d B", int64(c))
+}
+
+// Count converts b to an exact ByteCount. Fractional byte counts are
+// rounded to the nearest integer, with halves rounded away from zero.
+func (b Byte) Count() (ByteCount, error) {
+	if math.IsNaN(float64(b)) || math.IsInf(float64(b), 0) {
+		return 0, ErrOverflow
+	}
+	return ratToCount(new(big.Rat).SetFloat64(float64(b)))
+}
+
+// Byte converts c to a Byte. Precision is lost when c is above 2^53.
+func (c ByteCount) Byte() Byte {
+	return Byte(c)
+}
+
+// String returns the exact representation of c in the Legacy system
+func (c ByteCount) String() string {
+	return Legacy.FormatCount(c)
+}
+
+// Format returns the exact representation of c in the given system
+func (c ByteCount) Format(s System) string {
+	return s.FormatCount(c)
+}
+
+// Gt returns whether the value c is greater than v
+func (c ByteCount) Gt(v int64) bool {
+	return int64(c) > v
+}
+
+// Lt returns whether the value c is less than v
+func (c ByteCount) Lt(v int64) bool {
+	return int64(c) < v
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c ByteCount) MarshalJSON() ([]byte, error) {
+	return []byte(strings.Join([]string{"\"", c.String(), "\""}, "")), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It accepts either a quoted byte size (e.g. "1 MB"), or a plain number of
+// bytes (e.g. 1048576).
+func (c *ByteCount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	var r ByteCount
+	var err error
+	if strings.HasPrefix(s, "\"") {
+		r, err = ParseByteCount(strings.Trim(s, "\""))
+	} else {
+		p := ByteParser{DefaultUnit: 1}
+		r, err = p.ParseCount(s)
+	}
+	if err != nil {
+		return err
+	}
+	*c = r
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (c ByteCount) MarshalText() (text []byte, err error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (c *ByteCount) UnmarshalText(text []byte) error {
+	r, err := ParseByteCount(string(text))
+	if err != nil {
+		return err
+	}
+	*c = r
+	return nil
+}
+
+// ratToCount rounds r to the nearest ByteCount
+func ratToCount(r *big.Rat) (ByteCount, error) {
+	i, ok := roundRat(r)
+	if !ok {
+		return 0, ErrOverflow
+	}
+	return ByteCount(i), nil
+}
+
+// roundRat rounds r to the nearest integer, with halves rounded away from
+// zero. It returns false when the result does not fit in an int64.
+func roundRat(r *big.Rat) (int64, bool) {
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Abs(m).Lsh(m, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Num().Sign())))
+	}
+	if !q.IsInt64() {
+		return 0, false
+	}
+	return q.Int64(), true
+}
+
+// formatRat returns the exact decimal representation of r.
+// The denominator of r must be a power of 2 or 10, which is the case
+// for all scales, so the expansion is finite.
+func formatRat(r *big.Rat) string {
+	s := r.FloatString(r.Denom().BitLen())
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"testing/quick"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestByteCountParse(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		sys    unit.System
+		in     string
+		expect unit.ByteCount
+		err    error
+	}{
+		{sys: unit.Legacy, in: "3 kB", expect: 3072},
+		{sys: unit.Legacy, in: "3.3 kB", expect: 3379},
+		{sys: unit.Legacy, in: "-3.3 kB", expect: -3379},
+		{sys: unit.Legacy, in: "0.5 B", expect: 1},
+		{sys: unit.Legacy, in: "-0.5 B", expect: -1},
+		{sys: unit.Legacy, in: "0.4 B", expect: 0},
+		{sys: unit.SI, in: "3.3 kB", expect: 3300},
+		{sys: unit.SI, in: "9.223372036854775807 EB", expect: math.MaxInt64},
+		{sys: unit.SI, in: "9.223372036854775808 EB", err: unit.ErrOverflow},
+		{sys: unit.IEC, in: "7 EiB", expect: 7 << 60},
+		{sys: unit.IEC, in: "8 EiB", err: unit.ErrOverflow},
+		{sys: unit.IEC, in: "9007199254740993 B", expect: 9007199254740993},
+	}
+
+	for i, test := range table {
+		res, err := test.sys.ParseCount(test.in)
+		if err != test.err {
+			t.Errorf("#%d - expect to get error %v, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %d, but got %d", i, test.expect, res)
+		}
+	}
+
+	if _, err := unit.ParseByteCount("1/2 kB"); err == nil {
+		t.Error("expect fractions to be rejected")
+	}
+}
+
+func TestByteCountFormat(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		sys    unit.System
+		in     unit.ByteCount
+		expect string
+	}{
+		{sys: unit.Legacy, in: 3072, expect: "3 kB"},
+		{sys: unit.Legacy, in: 3379, expect: "3.2998046875 kB"},
+		{sys: unit.Legacy, in: 0, expect: "0 B"},
+		{sys: unit.SI, in: 3300, expect: "3.3 kB"},
+		{sys: unit.SI, in: -999, expect: "-999 B"},
+		{sys: unit.SI, in: math.MaxInt64, expect: "9.223372036854775807 EB"},
+		{sys: unit.SI, in: math.MinInt64, expect: "-9.223372036854775808 EB"},
+		{sys: unit.IEC, in: 9007199254740993, expect: "8.00000000000000088817841970012523233890533447265625 PiB"},
+	}
+
+	for i, test := range table {
+		res := test.in.Format(test.sys)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, res)
+		}
+
+		// Formatting must be lossless
+		back, err := test.sys.ParseCount(res)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+		}
+		if back != test.in {
+			t.Errorf("#%d - expect to parse back %d, but got %d", i, test.in, back)
+		}
+	}
+}
+
+func TestByteCountConversion(t *testing.T) {
+	t.Parallel()
+
+	c, err := (unit.KB * 3.3).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 3379 {
+		t.Errorf("expect to get 3379, but got %d", c)
+	}
+	if c.Byte() != 3379 {
+		t.Errorf("expect to get 3379, but got %f", c.Byte())
+	}
+
+	for _, b := range []unit.Byte{unit.Byte(math.NaN()), unit.Byte(math.Inf(1)), unit.Byte(1e19)} {
+		if _, err := b.Count(); err != unit.ErrOverflow {
+			t.Errorf("expect overflow for %f, but got %v", b, err)
+		}
+	}
+}
+
+func TestByteCountJSON(t *testing.T) {
+	t.Parallel()
+
+	in := unit.ByteCount(9007199254740993)
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out unit.ByteCount
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if in != out {
+		t.Errorf("expect to get %d, but got %d (%s)", in, out, data)
+	}
+}
+
+// TestByteCountCanonicalRoundTrip ensures that formatting is lossless
+func TestByteCountCanonicalRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, sys := range []unit.System{unit.Legacy, unit.IEC, unit.SI} {
+		sys := sys
+		f := func(c int64) bool {
+			res, err := sys.ParseCount(sys.FormatCount(unit.ByteCount(c)))
+			return err == nil && res == unit.ByteCount(c)
+		}
+		if err := quick.Check(f, &quick.Config{MaxCount: 5000}); err != nil {
+			t.Errorf("%s - %s", sys, err)
+		}
+	}
+}
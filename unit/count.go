@@ -0,0 +1,71 @@
+package unit
+
+// Count is a number of occurrences
+//
+// e.g. 1.2k, 3M, 42, 1.2k requests
+type Count float64
+
+// CountDimension parses and formats counts with short suffixes
+var CountDimension = &Dimension{
+	Units: []Unit{
+		{Symbol: "", Factor: 1},
+		{Symbol: "k", Factor: 1e3, Aliases: []string{"K"}},
+		{Symbol: "M", Factor: 1e6},
+		{Symbol: "G", Factor: 1e9, Aliases: []string{"B"}},
+		{Symbol: "T", Factor: 1e12},
+	},
+	AnyNoun: true,
+}
+
+// ParseOccurrences parses the string and returns a Count. The noun naming
+// what is counted is ignored.
+//
+// e.g. 1.2k, 3M, 42, 1.2k requests
+func ParseOccurrences(s string) (Count, error) {
+	v, err := CountDimension.Parse(s)
+	return Count(v), err
+}
+
+func (c Count) String() string {
+	return CountDimension.Format(float64(c))
+}
+
+// Gt returns whether the value c is greater than v
+func (c Count) Gt(v int64) bool {
+	return c > Count(v)
+}
+
+// Lt returns whether the value c is less than v
+func (c Count) Lt(v int64) bool {
+	return c < Count(v)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c Count) MarshalJSON() ([]byte, error) {
+	return CountDimension.EncodeJSON(float64(c))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *Count) UnmarshalJSON(data []byte) error {
+	return CountDimension.DecodeJSON(data, (*float64)(c))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (c Count) MarshalText() ([]byte, error) {
+	return CountDimension.EncodeText(float64(c))
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (c *Count) UnmarshalText(text []byte) error {
+	return CountDimension.DecodeText(text, (*float64)(c))
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (c Count) GobEncode() ([]byte, error) {
+	return CountDimension.EncodeGob(float64(c))
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (c *Count) GobDecode(data []byte) error {
+	return CountDimension.DecodeGob(data, (*float64)(c))
+}
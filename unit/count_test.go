@@ -0,0 +1,87 @@
+package unit_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		expect unit.Count
+		str    string
+	}{
+		{in: "42", expect: 42, str: "42"},
+		{in: "1.2k", expect: 1200, str: "1.2k"},
+		{in: "1.2K", expect: 1200, str: "1.2k"},
+		{in: "3 M", expect: 3e6, str: "3M"},
+		{in: "7B", expect: 7e9, str: "7G"},
+		{in: "1.2k requests", expect: 1200, str: "1.2k"},
+		{in: "3 M requests", expect: 3e6, str: "3M"},
+		{in: "42 hits", expect: 42, str: "42"},
+	}
+
+	for i, test := range table {
+		res, err := unit.ParseOccurrences(test.in)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+		if res.String() != test.str {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.str, res)
+		}
+	}
+	if _, err := unit.ParseOccurrences("1.2krequests"); err == nil {
+		t.Errorf("expect the noun to be separated by a space")
+	}
+}
+
+func TestCountEncoding(t *testing.T) {
+	t.Parallel()
+
+	type stats struct {
+		Hits unit.Count `json:"hits"`
+	}
+
+	var s stats
+	if err := json.Unmarshal([]byte(`{"hits":1500}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Hits != 1500 {
+		t.Errorf("expect to get 1500, but got %f", s.Hits)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"hits":"1.5k"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	if err := json.Unmarshal(data, &s); err != nil || s.Hits != 1500 {
+		t.Errorf("expect to decode 1500, but got %f (%v)", s.Hits, err)
+	}
+	if err := json.Unmarshal([]byte(`{"hits":null}`), &s); err != nil || s.Hits != 1500 {
+		t.Errorf("expect null to leave 1500, but got %f (%v)", s.Hits, err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(unit.Count(0.1)); err != nil {
+		t.Fatal(err)
+	}
+	var c unit.Count
+	if err := gob.NewDecoder(&buf).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c != 0.1 {
+		t.Errorf("expect to get 0.1, but got %f", c)
+	}
+}
@@ -0,0 +1,415 @@
+package unit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Unit is a named multiple of the base unit of a dimension
+type Unit struct {
+	// Symbol is written after the number (e.g. kHz, k, min)
+	Symbol string
+	// Factor is the value of the unit expressed in base units (e.g. 1e3 for kHz)
+	Factor float64
+	// Aliases are alternative symbols accepted when parsing (e.g. K for k)
+	Aliases []string
+	// ParseOnly units are accepted when parsing, but never used for formatting
+	ParseOnly bool
+}
+
+// Dimension defines how quantities of the same kind are parsed and formatted
+//
+// Values are expressed in base units, which is the unit with a factor of 1.
+// A dimension with a unit without symbol accepts bare numbers.
+//
+// Values of a dimension are held by a Quantity, which provides their JSON,
+// Text and Gob encodings, and their comparisons. Types which need constants
+// and arithmetic are defined as a float64 whose methods delegate to their
+// dimension instead (see Count, Frequency and Byte).
+//
+// e.g. a frequency is expressed in Hz, kHz, MHz, ...
+type Dimension struct {
+	// Units are all the units of the dimension
+	Units []Unit
+	// Separator is written between the number and the symbol (e.g. a space)
+	Separator string
+	// Suffix is written after the symbol when formatting (e.g. " requests"),
+	// and it is optional when parsing
+	Suffix string
+	// AnyNoun accepts any word naming what is counted after the symbol when
+	// parsing (e.g. 1.2k requests), as long as it is separated by a space
+	AnyNoun bool
+	// CaseInsensitive accepts symbols in any case
+	CaseInsensitive bool
+	// Digits is the number of significant digits used for formatting. Zero
+	// uses the smallest number of digits necessary to represent the value exactly.
+	Digits int
+	// Exact formats values with the largest unit which represents them
+	// exactly, so they parse back to the same value. Digits is ignored.
+	Exact bool
+}
+
+// Parse parses s and returns its value in base units
+//
+// e.g. 1.2k requests, 3 GHz, 90 min
+func (d *Dimension) Parse(s string) (float64, error) {
+	sc := numberScanner{decimal: '.'}
+	in := []rune(s)
+	n, i, perr := sc.scan(in)
+	if perr != nil {
+		perr.Input = s
+		return 0, perr
+	}
+
+	symbol := compact(string(in[i:]))
+	if suffix := compact(d.Suffix); suffix != "" && d.hasSuffix(symbol, suffix) {
+		symbol = symbol[:len(symbol)-len(suffix)]
+	}
+	u, ok := d.lookup(symbol)
+	if !ok && d.AnyNoun {
+		u, ok = d.lookupNoun(string(in[i:]), i > 0 && unicode.IsSpace(in[i-1]))
+	}
+	if !ok {
+		if symbol == "" {
+			return 0, &ParseError{Input: s, Pos: i, Msg: "missing unit"}
+		}
+		return 0, &ParseError{Input: s, Pos: i, Msg: "unknown unit " + symbol}
+	}
+
+	v, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return 0, &ParseError{Input: s, Msg: "number out of range"}
+	}
+	return v * u.Factor, nil
+}
+
+// Format returns the representation of v, which is expressed in base units.
+// It uses the largest unit for which the value is at least 1 (and which
+// represents it exactly when Exact is set).
+func (d *Dimension) Format(v float64) string {
+	var u Unit
+	var n string
+	if d.Exact {
+		u, n = d.formatExact(v)
+	} else {
+		u = d.pick(v)
+		n = formatSignificant(v/u.Factor, d.Digits)
+	}
+	if u.Symbol == "" {
+		return n + d.Suffix
+	}
+	return n + d.Separator + u.Symbol + d.Suffix
+}
+
+// EncodeText encodes v with its string representation
+func (d *Dimension) EncodeText(v float64) ([]byte, error) {
+	return []byte(d.Format(v)), nil
+}
+
+// DecodeText decodes a value encoded by EncodeText into v
+func (d *Dimension) DecodeText(text []byte, v *float64) error {
+	r, err := d.Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*v = r
+	return nil
+}
+
+// EncodeJSON encodes v as a quoted string representation
+func (d *Dimension) EncodeJSON(v float64) ([]byte, error) {
+	return []byte(strconv.Quote(d.Format(v))), nil
+}
+
+// DecodeJSON decodes either a quoted string representation, or a plain
+// number expressed in base units, into v. null leaves v unchanged.
+func (d *Dimension) DecodeJSON(data []byte, v *float64) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	var r float64
+	var err error
+	if strings.HasPrefix(s, "\"") {
+		if s, err = strconv.Unquote(s); err != nil {
+			return err
+		}
+		r, err = d.Parse(s)
+	} else {
+		r, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return err
+	}
+	*v = r
+	return nil
+}
+
+// EncodeGob encodes v for the gob package
+func (d *Dimension) EncodeGob(v float64) ([]byte, error) {
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
+
+// DecodeGob decodes a value encoded by EncodeGob into v
+func (d *Dimension) DecodeGob(data []byte, v *float64) error {
+	r, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return err
+	}
+	*v = r
+	return nil
+}
+
+// Quantity returns the quantity of v base units of the dimension
+func (d *Dimension) Quantity(v float64) Quantity {
+	return Quantity{Value: v, Dimension: d}
+}
+
+// lookup finds the unit with the given compact symbol or alias
+func (d *Dimension) lookup(symbol string) (Unit, bool) {
+	// Exact matches take precedence over case-insensitive ones (e.g. M and m)
+	for _, fold := range []bool{false, true} {
+		if fold && !d.CaseInsensitive {
+			break
+		}
+		for _, u := range d.Units {
+			for _, candidate := range append([]string{u.Symbol}, u.Aliases...) {
+				c := compact(candidate)
+				if c == symbol || (fold && strings.EqualFold(c, symbol)) {
+					return u, true
+				}
+			}
+		}
+	}
+	return Unit{}, false
+}
+
+// lookupNoun finds the unit at the beginning of s, which is followed by a
+// noun (e.g. "k requests"). Base units without symbol are directly followed
+// by the noun, when it is separated from the number (e.g. 42 requests).
+func (d *Dimension) lookupNoun(s string, spaced bool) (Unit, bool) {
+	fields := strings.Fields(s)
+	if len(fields) >= 2 {
+		if u, ok := d.lookup(fields[0]); ok {
+			return u, true
+		}
+	}
+	if len(fields) > 0 && spaced {
+		return d.lookup("")
+	}
+	return Unit{}, false
+}
+
+// formatExact returns the largest unit in which v is at least 1 and is
+// represented exactly, along with the number formatted in that unit. It
+// falls back to base units.
+func (d *Dimension) formatExact(v float64) (Unit, string) {
+	best := Unit{Factor: 1}
+	for _, u := range d.Units {
+		if !u.ParseOnly && u.Factor == 1 {
+			best = u
+			break
+		}
+	}
+	n := strconv.FormatFloat(v, 'f', -1, 64)
+
+	for _, u := range d.Units {
+		if u.ParseOnly || u.Factor <= best.Factor {
+			continue
+		}
+		x := v / u.Factor
+		if math.Abs(x) < 1 {
+			continue
+		}
+		xn := strconv.FormatFloat(x, 'f', -1, 64)
+		if r, err := strconv.ParseFloat(xn, 64); err == nil && r*u.Factor == v {
+			best, n = u, xn
+		}
+	}
+	return best, n
+}
+
+// pick returns the unit used to format v
+func (d *Dimension) pick(v float64) Unit {
+	best, smallest := Unit{Factor: 1}, Unit{Factor: math.Inf(1)}
+	found := false
+	for _, u := range d.Units {
+		if u.ParseOnly {
+			continue
+		}
+		if u.Factor < smallest.Factor {
+			smallest = u
+		}
+		if v == 0 && u.Factor == 1 {
+			return u
+		}
+		if math.Abs(v) >= u.Factor && (!found || u.Factor > best.Factor) {
+			best, found = u, true
+		}
+	}
+	if !found && !math.IsInf(smallest.Factor, 1) {
+		return smallest
+	}
+	return best
+}
+
+func (d *Dimension) hasSuffix(s, suffix string) bool {
+	if len(s) < len(suffix) {
+		return false
+	}
+	if d.CaseInsensitive {
+		return strings.EqualFold(s[len(s)-len(suffix):], suffix)
+	}
+	return strings.HasSuffix(s, suffix)
+}
+
+// formatSignificant formats x rounded to the given number of significant
+// digits, without trailing zeros. A non-positive number of digits formats x
+// with the smallest number of digits necessary to represent it exactly.
+func formatSignificant(x float64, digits int) string {
+	if digits <= 0 || x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+
+	decimals := digits - int(math.Floor(math.Log10(math.Abs(x)))) - 1
+	if decimals < 0 {
+		// Pad the rounded mantissa with zeros (e.g. 1.23e+05 to 123000)
+		e := strconv.FormatFloat(x, 'e', digits-1, 64)
+		i := strings.IndexByte(e, 'e')
+		exp, _ := strconv.Atoi(e[i+1:])
+		n := strings.Replace(e[:i], ".", "", 1)
+		return n + strings.Repeat("0", exp-(digits-1))
+	}
+	n := strconv.FormatFloat(x, 'f', decimals, 64)
+	if strings.Contains(n, ".") {
+		n = strings.TrimSuffix(strings.TrimRight(n, "0"), ".")
+	}
+	return n
+}
+
+// compact removes all spaces from s
+func compact(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Dimension returns the dimension which parses and formats byte sizes in
+// the system s, and which drives Parse and Format. Its number format is
+// strict, unlike Parse which also accepts digit grouping.
+func (s System) Dimension() *Dimension {
+	d := *s.dimension()
+	d.Units = append([]Unit(nil), d.Units...)
+	return &d
+}
+
+// systemDimensions are the dimensions of each system
+var systemDimensions = map[System]*Dimension{
+	Legacy: newSystemDimension(Legacy),
+	IEC:    newSystemDimension(IEC),
+	SI:     newSystemDimension(SI),
+}
+
+// dimension returns the shared dimension of s, which must not be modified
+func (s System) dimension() *Dimension {
+	if d, ok := systemDimensions[s]; ok {
+		return d
+	}
+	return systemDimensions[Legacy]
+}
+
+func newSystemDimension(s System) *Dimension {
+	d := &Dimension{
+		Units:     []Unit{{Symbol: "B", Factor: 1}},
+		Separator: " ",
+		Exact:     true,
+	}
+	for _, sc := range s.scales() {
+		d.Units = append(d.Units, Unit{Symbol: sc.symbol, Factor: float64(sc.mag)})
+	}
+	// IEC symbols are unambiguous, so they are accepted by all systems.
+	// Decimal symbols are binary multiples in the Legacy system, and decimal
+	// multiples otherwise.
+	extra := iecScales
+	if s == IEC {
+		extra = siScales
+	}
+	for _, sc := range extra {
+		d.Units = append(d.Units, Unit{Symbol: sc.symbol, Factor: float64(sc.mag), ParseOnly: true})
+	}
+	return d
+}
+
+// Quantity is a value of a dimension. It implements the JSON, Text and Gob
+// encodings of its dimension, and comparisons, so custom units can be used
+// without defining a type (e.g. a number of requests).
+//
+// The dimension must be set before decoding a quantity (see
+// Dimension.Quantity). Quantities without dimension are bare numbers.
+type Quantity struct {
+	// Value is expressed in base units
+	Value float64
+	// Dimension parses and formats the value
+	Dimension *Dimension
+}
+
+func (q Quantity) String() string {
+	return q.dimension().Format(q.Value)
+}
+
+// Gt returns whether the value q is greater than v
+func (q Quantity) Gt(v int64) bool {
+	return q.Value > float64(v)
+}
+
+// Lt returns whether the value q is less than v
+func (q Quantity) Lt(v int64) bool {
+	return q.Value < float64(v)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return q.dimension().EncodeJSON(q.Value)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	return q.dimension().DecodeJSON(data, &q.Value)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (q Quantity) MarshalText() ([]byte, error) {
+	return q.dimension().EncodeText(q.Value)
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (q *Quantity) UnmarshalText(text []byte) error {
+	return q.dimension().DecodeText(text, &q.Value)
+}
+
+// GobEncode implements the gob.GobEncoder interface. Only the value is
+// encoded.
+func (q Quantity) GobEncode() ([]byte, error) {
+	return q.dimension().EncodeGob(q.Value)
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (q *Quantity) GobDecode(data []byte) error {
+	return q.dimension().DecodeGob(data, &q.Value)
+}
+
+// numberDimension is the dimension of quantities without dimension
+var numberDimension = &Dimension{Units: []Unit{{Factor: 1}}}
+
+func (q Quantity) dimension() *Dimension {
+	if q.Dimension != nil {
+		return q.Dimension
+	}
+	return numberDimension
+}
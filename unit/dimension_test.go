@@ -0,0 +1,204 @@
+package unit_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestDimensionParse(t *testing.T) {
+	t.Parallel()
+
+	requests := *unit.CountDimension
+	requests.Suffix = " requests"
+	requests.AnyNoun = false
+	requests.CaseInsensitive = true
+
+	table := []struct {
+		dim    *unit.Dimension
+		in     string
+		expect float64
+		pos    int
+		err    bool
+	}{
+		{dim: &requests, in: "1.2k requests", expect: 1200},
+		{dim: &requests, in: "1.2K", expect: 1200},
+		{dim: &requests, in: "5 M Requests", expect: 5e6},
+		{dim: &requests, in: "12 requests", expect: 12},
+		{dim: &requests, in: "12", expect: 12},
+		{dim: &requests, in: "12 calls", err: true, pos: 3},
+		{dim: unit.DurationDimension, in: "1.5h", expect: float64(90 * time.Minute)},
+		{dim: unit.DurationDimension, in: "90 min", expect: float64(90 * time.Minute)},
+		{dim: unit.DurationDimension, in: "7d", expect: float64(7 * 24 * time.Hour)},
+		{dim: unit.DurationDimension, in: "250us", expect: float64(250 * time.Microsecond)},
+		{dim: unit.DurationDimension, in: "7", err: true, pos: 1},
+		{dim: unit.IEC.Dimension(), in: "1.5 GiB", expect: float64(unit.GiB * 1.5)},
+		{dim: unit.IEC.Dimension(), in: "1 kB", expect: 1000},
+		{dim: unit.Legacy.Dimension(), in: "1 kB", expect: 1024},
+		{dim: unit.Legacy.Dimension(), in: "x kB", err: true, pos: 0},
+	}
+
+	for i, test := range table {
+		res, err := test.dim.Parse(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			if perr, ok := err.(*unit.ParseError); !ok || perr.Pos != test.pos {
+				t.Errorf("#%d - expect error at %d, but got %v", i, test.pos, err)
+			}
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+	}
+}
+
+func TestDimensionFormat(t *testing.T) {
+	t.Parallel()
+
+	requests := *unit.CountDimension
+	requests.Suffix = " requests"
+	requests.Digits = 3
+
+	table := []struct {
+		dim    *unit.Dimension
+		in     float64
+		expect string
+	}{
+		{dim: &requests, in: 1234, expect: "1.23k requests"},
+		{dim: &requests, in: 12, expect: "12 requests"},
+		{dim: &requests, in: 0, expect: "0 requests"},
+		{dim: &requests, in: 123456789, expect: "123M requests"},
+		{dim: unit.DurationDimension, in: float64(90 * time.Minute), expect: "1.5h"},
+		{dim: unit.DurationDimension, in: float64(500 * time.Millisecond), expect: "500ms"},
+		{dim: unit.DurationDimension, in: 0.5, expect: "0.5ns"},
+		{dim: unit.IEC.Dimension(), in: float64(unit.MiB * 1.5), expect: "1.5 MiB"},
+		{dim: unit.Legacy.Dimension(), in: float64(unit.KB * 3.3), expect: (unit.KB * 3.3).String()},
+	}
+
+	for i, test := range table {
+		res := test.dim.Format(test.in)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, res)
+		}
+	}
+}
+
+// TestQuantityComparison ensures that quantities defined with a dimension
+// can be compared like Byte
+func TestQuantityComparison(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		gt, lt bool
+		expect bool
+	}{
+		{gt: unit.Count(1200).Gt(1000), expect: true},
+		{gt: unit.Count(1000).Gt(1000), expect: false},
+		{lt: unit.Count(999.5).Lt(1000), expect: true},
+		{gt: (2 * unit.Kilohertz).Gt(1999), expect: true},
+		{lt: (2 * unit.Kilohertz).Lt(2000), expect: false},
+		{gt: unit.CountDimension.Quantity(3).Gt(2), expect: true},
+		{lt: unit.CountDimension.Quantity(3).Lt(3), expect: false},
+	}
+
+	for i, test := range table {
+		if res := test.gt || test.lt; res != test.expect {
+			t.Errorf("#%d - expect to get %t, but got %t", i, test.expect, res)
+		}
+	}
+}
+
+// TestQuantityEncoding ensures that quantities are encoded with their
+// dimension
+func TestQuantityEncoding(t *testing.T) {
+	t.Parallel()
+
+	requests := *unit.CountDimension
+	requests.Suffix = " requests"
+
+	type stats struct {
+		Hits unit.Quantity `json:"hits"`
+	}
+	s := stats{Hits: requests.Quantity(1500)}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"hits":"1.5k requests"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	table := []struct {
+		in     string
+		expect float64
+	}{
+		{in: `{"hits":"2k requests"}`, expect: 2000},
+		{in: `{"hits":42}`, expect: 42},
+		{in: `{"hits":null}`, expect: 1500},
+	}
+	for i, test := range table {
+		res := stats{Hits: requests.Quantity(1500)}
+		if err := json.Unmarshal([]byte(test.in), &res); err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+			continue
+		}
+		if res.Hits.Value != test.expect || res.Hits.Dimension != &requests {
+			t.Errorf("#%d - expect to get %f, but got %+v", i, test.expect, res.Hits)
+		}
+	}
+
+	text, err := unit.FrequencyDimension.Quantity(2.4e9).MarshalText()
+	if err != nil || string(text) != "2.4 GHz" {
+		t.Errorf("expect to get 2.4 GHz, but got %s (%v)", text, err)
+	}
+	q := unit.FrequencyDimension.Quantity(0)
+	if err := q.UnmarshalText([]byte("50 Hz")); err != nil || q.Value != 50 {
+		t.Errorf("expect to get 50, but got %f (%v)", q.Value, err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(requests.Quantity(0.1)); err != nil {
+		t.Fatal(err)
+	}
+	q = requests.Quantity(0)
+	if err := gob.NewDecoder(&buf).Decode(&q); err != nil || q.Value != 0.1 {
+		t.Errorf("expect to get 0.1, but got %f (%v)", q.Value, err)
+	}
+
+	if got := (unit.Quantity{Value: 12.5}).String(); got != "12.5" {
+		t.Errorf("expect a quantity without dimension to be a number, but got %s", got)
+	}
+}
+
+// TestByteDimension ensures that the dimension of a system is equivalent
+// to its Parse and Format
+func TestByteDimension(t *testing.T) {
+	t.Parallel()
+
+	for _, sys := range []unit.System{unit.Legacy, unit.IEC, unit.SI} {
+		sys, dim := sys, sys.Dimension()
+		f := func(b unit.Byte) bool {
+			s := dim.Format(float64(b))
+			if s != sys.Format(b) {
+				return false
+			}
+			v, err := dim.Parse(s)
+			return err == nil && v == float64(b)
+		}
+		if err := quick.Check(f, &quick.Config{MaxCount: 2000, Values: byteValues}); err != nil {
+			t.Errorf("%s - %s", sys, err)
+		}
+	}
+
+	if got := unit.SI.Dimension().Format(32548.46); got != unit.SI.Format(32548.46) {
+		t.Errorf("expect to get %s, but got %s", unit.SI.Format(32548.46), got)
+	}
+}
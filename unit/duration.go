@@ -0,0 +1,244 @@
+package unit
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Duration is a time.Duration which can also be expressed in days and weeks
+//
+// e.g. 7d, 2w 3d 4h, 1.5h, 1h30m
+type Duration time.Duration
+
+const (
+	// Day means 24 hours
+	Day = Duration(24 * time.Hour)
+	// Week means 7 days
+	Week = 7 * Day
+)
+
+// DurationDimension parses and formats durations expressed in nanoseconds,
+// with one unit at a time (e.g. 1.5h, 90 min, 7d).
+//
+// Duration resolves its units with this dimension, but it keeps its own
+// parser and encodings, since it is an exact number of nanoseconds made of
+// several units (e.g. 2w 3d 4h), which a float64 dimension cannot represent.
+var DurationDimension = &Dimension{
+	Units: []Unit{
+		{Symbol: "ns", Factor: float64(time.Nanosecond)},
+		{Symbol: "µs", Factor: float64(time.Microsecond), Aliases: []string{"us", "μs"}},
+		{Symbol: "ms", Factor: float64(time.Millisecond)},
+		{Symbol: "s", Factor: float64(time.Second)},
+		{Symbol: "min", Factor: float64(time.Minute), Aliases: []string{"m"}},
+		{Symbol: "h", Factor: float64(time.Hour)},
+		{Symbol: "d", Factor: float64(Day)},
+		{Symbol: "w", Factor: float64(Week)},
+	},
+}
+
+// durationComponents are the units used to format a Duration
+var durationComponents = []struct {
+	symbol string
+	d      Duration
+}{
+	{"w", Week},
+	{"d", Day},
+	{"h", Duration(time.Hour)},
+	{"m", Duration(time.Minute)},
+	{"s", Duration(time.Second)},
+	{"ms", Duration(time.Millisecond)},
+	{"µs", Duration(time.Microsecond)},
+	{"ns", Duration(time.Nanosecond)},
+}
+
+// ParseDuration parses a duration made of one or more decimal numbers, each
+// followed by a unit, and optionally separated by spaces. A sign is only
+// accepted in front of the first number.
+//
+// Valid units are "ns", "us" (or "µs"), "ms", "s", "m" (or "min"), "h", "d"
+// and "w". Fractions of nanoseconds are rounded to the nearest nanosecond.
+//
+// e.g. 7d, 2w 3d 4h, 1.5h, 1h30m, -90s
+func ParseDuration(s string) (Duration, error) {
+	in := []rune(s)
+	sc := numberScanner{decimal: '.'}
+	total := new(big.Rat)
+	neg := false
+
+	i := 0
+	for first := true; ; first = false {
+		for i < len(in) && unicode.IsSpace(in[i]) {
+			i++
+		}
+		if i == len(in) && !first {
+			break
+		}
+
+		n, j, perr := sc.scan(in[i:])
+		if perr != nil {
+			perr.Input = s
+			perr.Pos += i
+			return 0, perr
+		}
+		if n[0] == '-' || n[0] == '+' {
+			if !first {
+				return 0, &ParseError{Input: s, Pos: i, Msg: "unexpected sign"}
+			}
+			neg = n[0] == '-'
+			n = n[1:]
+		}
+
+		// The unit directly follows the number
+		start := i + j
+		end := start
+		for end < len(in) && unicode.IsLetter(in[end]) {
+			end++
+		}
+		symbol := string(in[start:end])
+		if symbol == "" {
+			// Zero does not need a unit
+			if first && end == len(in) {
+				if v, err := strconv.ParseFloat(n, 64); err == nil && v == 0 {
+					return 0, nil
+				}
+			}
+			return 0, &ParseError{Input: s, Pos: start, Msg: "missing unit"}
+		}
+		u, ok := DurationDimension.lookup(symbol)
+		if !ok {
+			return 0, &ParseError{Input: s, Pos: start, Msg: "unknown unit " + symbol}
+		}
+
+		v, ok := new(big.Rat).SetString(n)
+		if !ok {
+			return 0, &ParseError{Input: s, Msg: "number out of range"}
+		}
+		total.Add(total, v.Mul(v, new(big.Rat).SetInt64(int64(u.Factor))))
+		i = end
+	}
+
+	if neg {
+		total.Neg(total)
+	}
+	d, ok := roundRat(total)
+	if !ok {
+		return 0, &ParseError{Input: s, Msg: "duration out of range"}
+	}
+	return Duration(d), nil
+}
+
+// String returns the duration split into its components, from weeks down to
+// nanoseconds, omitting the zero ones.
+//
+// e.g. 2w 3d 4h, 1h 30m, 1s 500ms, 0s
+func (d Duration) String() string {
+	if d == 0 {
+		return "0s"
+	}
+
+	// Work on the absolute value, which fits in an uint64 even for math.MinInt64
+	u := uint64(d)
+	if d < 0 {
+		u = -u
+	}
+	var parts []string
+	for _, c := range durationComponents {
+		if q := u / uint64(c.d); q > 0 {
+			parts = append(parts, strconv.FormatUint(q, 10)+c.symbol)
+			u -= q * uint64(c.d)
+		}
+	}
+	s := strings.Join(parts, " ")
+	if d < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// Round returns the result of rounding d to the nearest multiple of m
+// (e.g. for display). Halfway values are rounded away from zero.
+func (d Duration) Round(m Duration) Duration {
+	return Duration(time.Duration(d).Round(time.Duration(m)))
+}
+
+// Truncate returns the result of rounding d toward zero to a multiple of m
+func (d Duration) Truncate(m Duration) Duration {
+	return Duration(time.Duration(d).Truncate(time.Duration(m)))
+}
+
+// Gt returns whether the value d is greater than v
+func (d Duration) Gt(v int64) bool {
+	return int64(d) > v
+}
+
+// Lt returns whether the value d is less than v
+func (d Duration) Lt(v int64) bool {
+	return int64(d) < v
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It accepts a quoted string, or a number of nanoseconds as time.Duration does.
+// null is a no-op.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if !strings.HasPrefix(s, "\"") {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*d = Duration(i)
+		return nil
+	}
+
+	s, err := strconv.Unquote(s)
+	if err != nil {
+		return err
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (d Duration) GobEncode() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(d), 10)), nil
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (d *Duration) GobDecode(data []byte) error {
+	i, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	*d = Duration(i)
+	return nil
+}
@@ -0,0 +1,144 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		expect unit.Duration
+		pos    int
+		err    bool
+	}{
+		{in: "7d", expect: 7 * unit.Day},
+		{in: "2w 3d 4h", expect: 2*unit.Week + 3*unit.Day + unit.Duration(4*time.Hour)},
+		{in: "2w3d4h", expect: 2*unit.Week + 3*unit.Day + unit.Duration(4*time.Hour)},
+		{in: "1.5h", expect: unit.Duration(90 * time.Minute)},
+		{in: "1h30m", expect: unit.Duration(90 * time.Minute)},
+		{in: "90 min", expect: unit.Duration(90 * time.Minute)},
+		{in: "-1.5d", expect: -36 * unit.Duration(time.Hour)},
+		{in: "250us", expect: unit.Duration(250 * time.Microsecond)},
+		{in: "250µs", expect: unit.Duration(250 * time.Microsecond)},
+		{in: "0.5ns", expect: 1},
+		{in: "15250w 1ns", expect: 15250*unit.Week + 1},
+		{in: "0", expect: 0},
+		{in: "", err: true, pos: 0},
+		{in: "7", err: true, pos: 1},
+		{in: "1h 30", err: true, pos: 5},
+		{in: "1h -30m", err: true, pos: 3},
+		{in: "3y", err: true, pos: 1},
+		{in: "16000w", err: true},
+		{in: "1e9999999h", err: true},
+	}
+
+	for i, test := range table {
+		res, err := unit.ParseDuration(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			if perr, ok := err.(*unit.ParseError); !ok || perr.Pos != test.pos {
+				t.Errorf("#%d - expect error at %d, but got %v", i, test.pos, err)
+			}
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %d, but got %d", i, test.expect, res)
+		}
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     unit.Duration
+		expect string
+	}{
+		{in: 0, expect: "0s"},
+		{in: 7 * unit.Day, expect: "1w"},
+		{in: 2*unit.Week + 3*unit.Day + unit.Duration(4*time.Hour), expect: "2w 3d 4h"},
+		{in: unit.Duration(90 * time.Minute), expect: "1h 30m"},
+		{in: unit.Duration(1500 * time.Millisecond), expect: "1s 500ms"},
+		{in: -unit.Day, expect: "-1d"},
+		{in: unit.Duration(math.MinInt64), expect: "-15250w 1d 23h 47m 16s 854ms 775µs 808ns"},
+	}
+
+	for i, test := range table {
+		res := test.in.String()
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.expect, res)
+		}
+
+		back, err := unit.ParseDuration(res)
+		if err != nil || back != test.in {
+			t.Errorf("#%d - expect to parse back %d, but got %d (%v)", i, test.in, back, err)
+		}
+	}
+}
+
+func TestDurationRound(t *testing.T) {
+	t.Parallel()
+
+	d := 3*unit.Day + unit.Duration(13*time.Hour+5*time.Minute)
+	if got := d.Round(unit.Day).String(); got != "4d" {
+		t.Errorf("expect to get 4d, but got %s", got)
+	}
+	if got := d.Truncate(unit.Day).String(); got != "3d" {
+		t.Errorf("expect to get 3d, but got %s", got)
+	}
+	if got := d.Round(unit.Duration(time.Hour)).String(); got != "3d 13h" {
+		t.Errorf("expect to get 3d 13h, but got %s", got)
+	}
+}
+
+func TestDurationCompare(t *testing.T) {
+	t.Parallel()
+
+	if !unit.Day.Gt(int64(23 * time.Hour)) {
+		t.Errorf("expect a day to be greater than 23h")
+	}
+	if unit.Day.Lt(int64(24 * time.Hour)) {
+		t.Errorf("expect a day not to be less than 24h")
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		TTL unit.Duration `json:"ttl"`
+	}
+
+	var c config
+	if err := json.Unmarshal([]byte(`{"ttl":"7d"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.TTL != 7*unit.Day {
+		t.Errorf("expect to get 7d, but got %s", c.TTL)
+	}
+	if err := json.Unmarshal([]byte(`{"ttl":3600000000000}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.TTL != unit.Duration(time.Hour) {
+		t.Errorf("expect to get 1h, but got %s", c.TTL)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"ttl":"1h"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"ttl":null}`), &c); err != nil || c.TTL != unit.Duration(time.Hour) {
+		t.Errorf("expect null to leave 1h, but got %s (%v)", c.TTL, err)
+	}
+}
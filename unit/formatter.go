@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/deixis/pkg/lang"
+	"github.com/go-playground/locales"
+)
+
+// decimalSeparators overrides the CLDR decimal separator of languages
+var decimalSeparators = map[string]rune{
+	// CLDR uses a decimal point, but a comma is customary outside of amounts
+	"de-CH": ',',
+}
+
+// prefixNames maps each symbol to the name of its prefix
+var prefixNames = map[string]string{
+	"B":   "",
+	"kB":  "kilo",
+	"MB":  "mega",
+	"GB":  "giga",
+	"TB":  "tera",
+	"PB":  "peta",
+	"EB":  "exa",
+	"KiB": "kibi",
+	"MiB": "mebi",
+	"GiB": "gibi",
+	"TiB": "tebi",
+	"PiB": "pebi",
+	"EiB": "exbi",
+}
+
+// localPrefixNames overrides prefix names per base language
+var localPrefixNames = map[string]map[string]string{
+	"fr": {
+		"MB":  "méga",
+		"TB":  "téra",
+		"PB":  "péta",
+		"MiB": "mébi",
+		"TiB": "tébi",
+		"PiB": "pébi",
+	},
+}
+
+// byteNames holds the singular and plural names of a byte per base language
+var byteNames = map[string][2]string{
+	"en": {"byte", "bytes"},
+	"fr": {"octet", "octets"},
+	"de": {"Byte", "Bytes"},
+	"it": {"byte", "byte"},
+}
+
+// ByteFormatter formats byte sizes according to a language
+type ByteFormatter struct {
+	// Lang is the language used to format numbers and unit names
+	Lang lang.Tag
+	// System is the convention used to name multiples of a byte
+	System System
+	// Precision is the number of decimals. A negative precision uses the
+	// smallest number of decimals necessary to represent the value exactly.
+	Precision int
+	// LongNames spells out unit names (e.g. "2 kilobytes" instead of "2 kB")
+	LongNames bool
+	// Decimal overrides the decimal separator of the language when it is set
+	// (e.g. '.' to write 3.3 kB in French). Separators follow CLDR by
+	// default, except in Swiss German, which uses a comma.
+	Decimal rune
+}
+
+// FormatByte formats b with the number format of the given language
+//
+// e.g. 3.3 kB (en), 3,3 kB (fr-CH), 3,3 kB (de-CH), 1’000 B (de-CH)
+func FormatByte(b Byte, lang lang.Tag) string {
+	f := ByteFormatter{Lang: lang, Precision: -1}
+	return f.Format(b)
+}
+
+// Format returns the representation of b
+func (f *ByteFormatter) Format(b Byte) string {
+	tr := f.Lang.Translator()
+	v, symbol := f.System.reduce(b)
+	digits := f.Precision
+	if digits < 0 {
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		digits = 0
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			digits = len(s) - i - 1
+		}
+	}
+	n := tr.FmtNumber(v, uint64(digits))
+	if d := f.decimal(); d != 0 && digits > 0 {
+		decimal, _ := separators(tr)
+		if i := strings.LastIndex(n, string(decimal)); i >= 0 {
+			n = n[:i] + string(d) + n[i+len(string(decimal)):]
+		}
+	}
+	if !f.LongNames {
+		return n + " " + symbol
+	}
+
+	names, ok := byteNames[f.Lang.Base()]
+	if !ok {
+		names = byteNames["en"]
+	}
+	name := names[1]
+	if tr.CardinalPluralRule(v, uint64(digits)) == locales.PluralRuleOne {
+		name = names[0]
+	}
+	prefix, ok := localPrefixNames[f.Lang.Base()][symbol]
+	if !ok {
+		prefix = prefixNames[symbol]
+	}
+	if prefix != "" {
+		if f.Lang.Base() == "de" {
+			prefix = strings.ToUpper(prefix[:1]) + prefix[1:]
+		}
+		name = prefix + strings.ToLower(name)
+	}
+	return n + " " + name
+}
+
+// decimal returns the decimal separator, or 0 to keep the one of the language
+func (f *ByteFormatter) decimal() rune {
+	if f.Decimal != 0 {
+		return f.Decimal
+	}
+	return decimalSeparators[f.Lang.String()]
+}
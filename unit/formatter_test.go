@@ -0,0 +1,117 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/deixis/pkg/lang"
+	"github.com/deixis/pkg/unit"
+)
+
+func TestFormatByte(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     unit.Byte
+		lang   lang.Tag
+		expect string
+	}{
+		{in: unit.KB * 3.3, lang: lang.English, expect: "3.3 kB"},
+		{in: unit.KB * 3.3, lang: lang.SwissFrench, expect: "3,3 kB"},
+		{in: unit.KB * 3.3, lang: lang.German, expect: "3,3 kB"},
+		{in: unit.KB * 3.3, lang: lang.SwissGerman, expect: "3,3 kB"},
+		{in: unit.KB * 3.3, lang: lang.Spanish, expect: "3.3 kB"},
+		{in: 1000, lang: lang.English, expect: "1,000 B"},
+		{in: 1000, lang: lang.SwissGerman, expect: "1’000 B"},
+		{in: -unit.MB * 5, lang: lang.SwissFrench, expect: "-5 MB"},
+	}
+
+	for i, test := range table {
+		res := unit.FormatByte(test.in, test.lang)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %q, but got %q", i, test.expect, res)
+		}
+	}
+}
+
+func TestByteFormatter(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in        unit.Byte
+		formatter unit.ByteFormatter
+		expect    string
+	}{
+		{
+			in:        unit.KB * 3.3,
+			formatter: unit.ByteFormatter{Lang: lang.English, Precision: 0},
+			expect:    "3 kB",
+		},
+		{
+			in:        unit.KB * 3.3,
+			formatter: unit.ByteFormatter{Lang: lang.SwissFrench, Precision: 2},
+			expect:    "3,30 kB",
+		},
+		{
+			in:        unit.KB * 3.3,
+			formatter: unit.ByteFormatter{Lang: lang.SwissFrench, Precision: 1, Decimal: '.'},
+			expect:    "3.3 kB",
+		},
+		{
+			in:        1000.5,
+			formatter: unit.ByteFormatter{Lang: lang.SwissGerman, Precision: 2},
+			expect:    "1’000,50 B",
+		},
+		{
+			in:        unit.MiB * 1.5,
+			formatter: unit.ByteFormatter{Lang: lang.English, System: unit.IEC, Precision: -1},
+			expect:    "1.5 MiB",
+		},
+		{
+			in:        unit.KB,
+			formatter: unit.ByteFormatter{Lang: lang.English, Precision: -1, LongNames: true},
+			expect:    "1 kilobyte",
+		},
+		{
+			in:        unit.KB * 2,
+			formatter: unit.ByteFormatter{Lang: lang.English, Precision: -1, LongNames: true},
+			expect:    "2 kilobytes",
+		},
+		{
+			in:        unit.KB * 1.5,
+			formatter: unit.ByteFormatter{Lang: lang.English, Precision: -1, LongNames: true},
+			expect:    "1.5 kilobytes",
+		},
+		{
+			in:        1,
+			formatter: unit.ByteFormatter{Lang: lang.English, Precision: -1, LongNames: true},
+			expect:    "1 byte",
+		},
+		{
+			in:        unit.KB,
+			formatter: unit.ByteFormatter{Lang: lang.German, Precision: -1, LongNames: true},
+			expect:    "1 Kilobyte",
+		},
+		{
+			in:        unit.KB * 2,
+			formatter: unit.ByteFormatter{Lang: lang.SwissGerman, Precision: -1, LongNames: true},
+			expect:    "2 Kilobytes",
+		},
+		{
+			in:        unit.DecimalMB * 2,
+			formatter: unit.ByteFormatter{Lang: lang.SwissFrench, System: unit.SI, Precision: -1, LongNames: true},
+			expect:    "2 mégaoctets",
+		},
+		{
+			in:        unit.GiB,
+			formatter: unit.ByteFormatter{Lang: lang.Italian, System: unit.IEC, Precision: -1, LongNames: true},
+			expect:    "1 gibibyte",
+		},
+	}
+
+	for i, test := range table {
+		res := test.formatter.Format(test.in)
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %q, but got %q", i, test.expect, res)
+		}
+	}
+}
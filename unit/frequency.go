@@ -0,0 +1,81 @@
+package unit
+
+// Frequency represents a frequency in hertz
+type Frequency float64
+
+const (
+	// Hertz means one cycle per second
+	Hertz Frequency = 1
+	// Kilohertz means 1000 Hz
+	Kilohertz Frequency = 1e3
+	// Megahertz means 1000 kHz
+	Megahertz Frequency = 1e6
+	// Gigahertz means 1000 MHz
+	Gigahertz Frequency = 1e9
+	// Terahertz means 1000 GHz
+	Terahertz Frequency = 1e12
+)
+
+// FrequencyDimension parses and formats frequencies
+var FrequencyDimension = &Dimension{
+	Units: []Unit{
+		{Symbol: "Hz", Factor: float64(Hertz)},
+		{Symbol: "kHz", Factor: float64(Kilohertz), Aliases: []string{"KHz"}},
+		{Symbol: "MHz", Factor: float64(Megahertz)},
+		{Symbol: "GHz", Factor: float64(Gigahertz)},
+		{Symbol: "THz", Factor: float64(Terahertz)},
+	},
+	Separator: " ",
+}
+
+// ParseFrequency parses the string and returns a Frequency
+//
+// e.g. 50 Hz, 2.4 GHz, 100kHz
+func ParseFrequency(s string) (Frequency, error) {
+	v, err := FrequencyDimension.Parse(s)
+	return Frequency(v), err
+}
+
+func (f Frequency) String() string {
+	return FrequencyDimension.Format(float64(f))
+}
+
+// Gt returns whether the value f is greater than v
+func (f Frequency) Gt(v int64) bool {
+	return f > Frequency(v)
+}
+
+// Lt returns whether the value f is less than v
+func (f Frequency) Lt(v int64) bool {
+	return f < Frequency(v)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (f Frequency) MarshalJSON() ([]byte, error) {
+	return FrequencyDimension.EncodeJSON(float64(f))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (f *Frequency) UnmarshalJSON(data []byte) error {
+	return FrequencyDimension.DecodeJSON(data, (*float64)(f))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (f Frequency) MarshalText() ([]byte, error) {
+	return FrequencyDimension.EncodeText(float64(f))
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (f *Frequency) UnmarshalText(text []byte) error {
+	return FrequencyDimension.DecodeText(text, (*float64)(f))
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (f Frequency) GobEncode() ([]byte, error) {
+	return FrequencyDimension.EncodeGob(float64(f))
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (f *Frequency) GobDecode(data []byte) error {
+	return FrequencyDimension.DecodeGob(data, (*float64)(f))
+}
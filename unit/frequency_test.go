@@ -0,0 +1,59 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestFrequency(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		expect unit.Frequency
+		str    string
+		err    bool
+	}{
+		{in: "50 Hz", expect: 50 * unit.Hertz, str: "50 Hz"},
+		{in: "2.4 GHz", expect: 2.4 * unit.Gigahertz, str: "2.4 GHz"},
+		{in: "100kHz", expect: 100 * unit.Kilohertz, str: "100 kHz"},
+		{in: "0.5 Hz", expect: 0.5, str: "0.5 Hz"},
+		{in: "100", err: true},
+		{in: "100 hz", err: true},
+	}
+
+	for i, test := range table {
+		res, err := unit.ParseFrequency(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+		if res.String() != test.str {
+			t.Errorf("#%d - expect to get %s, but got %s", i, test.str, res)
+		}
+	}
+}
+
+func TestFrequencyJSON(t *testing.T) {
+	t.Parallel()
+
+	in := 2.4 * unit.Gigahertz
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out unit.Frequency
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if in != out {
+		t.Errorf("expect to get %s, but got %s", in, out)
+	}
+}
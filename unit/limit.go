@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"io"
+	"math"
+)
+
+// TooLargeError is returned when more data than allowed is transferred
+type TooLargeError struct {
+	// Limit is the maximum size allowed
+	Limit Byte
+	// Size is the size observed when the limit was exceeded. When data is
+	// streamed, it is a lower bound of the actual size.
+	Size Byte
+}
+
+func (e *TooLargeError) Error() string {
+	return "too large: " + e.Size.String() + " exceeds the limit of " + e.Limit.String()
+}
+
+// LimitReader returns a Reader that reads from r, but fails with a
+// *TooLargeError once more than n bytes are read. A negative limit allows
+// no bytes.
+func LimitReader(r io.Reader, n Byte) io.Reader {
+	return &limitedReader{r: r, limit: clampLimit(n)}
+}
+
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		return 0, l.err()
+	}
+
+	// Read one extra byte to detect whether the limit is exceeded
+	if rem := l.limit - l.read + 1; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n - int(l.read-l.limit), l.err()
+	}
+	return n, err
+}
+
+func (l *limitedReader) err() error {
+	return &TooLargeError{Limit: Byte(l.limit), Size: Byte(l.read)}
+}
+
+// LimitWriter returns a Writer that writes to w, but fails with a
+// *TooLargeError once more than n bytes are written. The bytes within
+// the limit are written before failing. A negative limit allows no bytes.
+func LimitWriter(w io.Writer, n Byte) io.Writer {
+	return &limitedWriter{w: w, limit: clampLimit(n)}
+}
+
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	rem := l.limit - l.written
+	if int64(len(p)) <= rem {
+		n, err := l.w.Write(p)
+		l.written += int64(n)
+		return n, err
+	}
+
+	n, err := l.w.Write(p[:rem])
+	l.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, &TooLargeError{Limit: Byte(l.limit), Size: Byte(l.written + int64(len(p)) - int64(n))}
+}
+
+// clampLimit converts n to a number of bytes between 0 and the largest limit
+// which can be exceeded by one byte
+func clampLimit(n Byte) int64 {
+	switch {
+	case n >= math.MaxInt64:
+		return math.MaxInt64 - 1
+	case n > 0:
+		return int64(n)
+	}
+	return 0 // negative or NaN
+}
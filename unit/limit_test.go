@@ -0,0 +1,109 @@
+package unit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestLimitReader(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in    string
+		limit unit.Byte
+		err   bool
+	}{
+		{in: "", limit: 0},
+		{in: "hello", limit: 5},
+		{in: "hello", limit: unit.KB},
+		{in: "hello", limit: 4, err: true},
+		{in: "hello", limit: 0, err: true},
+	}
+
+	for i, test := range table {
+		got, err := ioutil.ReadAll(unit.LimitReader(strings.NewReader(test.in), test.limit))
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			tooLarge, ok := err.(*unit.TooLargeError)
+			if !ok {
+				t.Errorf("#%d - expect a TooLargeError, but got %T", i, err)
+				continue
+			}
+			if tooLarge.Limit != test.limit || tooLarge.Size != test.limit+1 {
+				t.Errorf("#%d - unexpected error %s", i, err)
+			}
+			if unit.Byte(len(got)) != test.limit {
+				t.Errorf("#%d - expect to read %d bytes, but got %d", i, int(test.limit), len(got))
+			}
+			continue
+		}
+		if string(got) != test.in {
+			t.Errorf("#%d - expect to read %s, but got %s", i, test.in, got)
+		}
+	}
+}
+
+func TestLimitWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := unit.LimitWriter(&buf, 8)
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write([]byte(" world"))
+	if n != 3 {
+		t.Errorf("expect to write 3 bytes, but got %d", n)
+	}
+	tooLarge, ok := err.(*unit.TooLargeError)
+	if !ok {
+		t.Fatalf("expect a TooLargeError, but got %v", err)
+	}
+	if tooLarge.Limit != 8 || tooLarge.Size != 11 {
+		t.Errorf("unexpected error %s", err)
+	}
+	if buf.String() != "hello wo" {
+		t.Errorf("expect to get <hello wo>, but got <%s>", buf.String())
+	}
+	if got := tooLarge.Error(); got != "too large: 11 B exceeds the limit of 8 B" {
+		t.Errorf("unexpected message %s", got)
+	}
+}
+
+// TestLimitBounds ensures that negative limits allow no bytes, and that
+// huge limits do not overflow
+func TestLimitBounds(t *testing.T) {
+	t.Parallel()
+
+	for _, limit := range []unit.Byte{-5, unit.Byte(math.NaN())} {
+		got, err := ioutil.ReadAll(unit.LimitReader(strings.NewReader("hello"), limit))
+		if tooLarge, ok := err.(*unit.TooLargeError); !ok || tooLarge.Limit != 0 || len(got) != 0 {
+			t.Errorf("%s - expect to read nothing, but got %q (%v)", limit, got, err)
+		}
+
+		var buf bytes.Buffer
+		n, err := unit.LimitWriter(&buf, limit).Write([]byte("hello"))
+		if tooLarge, ok := err.(*unit.TooLargeError); !ok || tooLarge.Limit != 0 || n != 0 || buf.Len() != 0 {
+			t.Errorf("%s - expect to write nothing, but got %d (%v)", limit, n, err)
+		}
+	}
+
+	for _, limit := range []unit.Byte{math.MaxInt64, unit.Byte(math.Inf(1))} {
+		got, err := ioutil.ReadAll(unit.LimitReader(strings.NewReader("hello"), limit))
+		if err != nil || string(got) != "hello" {
+			t.Errorf("%s - expect to read hello, but got %q (%v)", limit, got, err)
+		}
+
+		var buf bytes.Buffer
+		if _, err := unit.LimitWriter(&buf, limit).Write([]byte("hello")); err != nil || buf.String() != "hello" {
+			t.Errorf("%s - expect to write hello, but got %q (%v)", limit, buf.String(), err)
+		}
+	}
+}
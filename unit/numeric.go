@@ -0,0 +1,37 @@
+package unit
+
+import (
+	"math"
+	"strconv"
+)
+
+// NumericByte is a Byte which is encoded in JSON as a plain number of bytes
+// (e.g. 1048576 instead of "1 MB"). It is meant for machine APIs, and it
+// decodes both numbers and strings like Byte does.
+//
+// Fractional byte sizes are rounded to the nearest byte when encoded.
+type NumericByte Byte
+
+func (b NumericByte) String() string {
+	return Byte(b).String()
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (b NumericByte) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(math.Round(float64(b)), 'f', -1, 64)), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (b *NumericByte) UnmarshalJSON(data []byte) error {
+	return (*Byte)(b).UnmarshalJSON(data)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (b NumericByte) MarshalText() ([]byte, error) {
+	return Byte(b).MarshalText()
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (b *NumericByte) UnmarshalText(text []byte) error {
+	return (*Byte)(b).UnmarshalText(text)
+}
@@ -0,0 +1,85 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestByteUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		expect unit.Byte
+		err    bool
+	}{
+		{in: `"1 MB"`, expect: unit.MB},
+		{in: `"3.3 kB"`, expect: unit.KB * 3.3},
+		{in: `1048576`, expect: unit.MB},
+		{in: `1.5`, expect: 1.5},
+		{in: `1e3`, expect: 1000},
+		{in: `-20`, expect: -20},
+		{in: `null`, expect: 0},
+		{in: `"1048576"`, err: true},
+		{in: `true`, err: true},
+	}
+
+	for i, test := range table {
+		var b unit.Byte
+		err := json.Unmarshal([]byte(test.in), &b)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err == nil && b != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, b)
+		}
+
+		var c unit.ByteCount
+		err = json.Unmarshal([]byte(test.in), &c)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if exact, _ := test.expect.Count(); err == nil && c != exact {
+			t.Errorf("#%d - expect to get %d, but got %d", i, exact, c)
+		}
+	}
+}
+
+func TestNumericByteJSON(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		Size  unit.NumericByte `json:"size"`
+		Quota unit.Byte        `json:"quota"`
+	}
+
+	in := payload{Size: unit.NumericByte(unit.MB), Quota: unit.GB}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"size":1048576,"quota":"1 GB"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	var out payload
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if in != out {
+		t.Errorf("expect to get %v, but got %v", in, out)
+	}
+	if err := json.Unmarshal([]byte(`{"size":"2 kB"}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Size != unit.NumericByte(2*unit.KB) {
+		t.Errorf("expect to get 2 kB, but got %s", out.Size)
+	}
+
+	data, _ = json.Marshal(unit.NumericByte(1.5))
+	if string(data) != "2" {
+		t.Errorf("expect to round to 2, but got %s", data)
+	}
+}
@@ -0,0 +1,259 @@
+package unit
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/deixis/pkg/lang"
+	"github.com/go-playground/locales"
+)
+
+// ByteParser parses byte sizes with a configurable leniency
+//
+// The zero value is strict: symbols are case sensitive and required, and
+// numbers use a dot as decimal separator without grouping.
+type ByteParser struct {
+	// System is the convention used to resolve decimal symbols
+	System System
+	// CaseInsensitive accepts symbols in any case (e.g. 10kb, 10 KB, 1 mib)
+	CaseInsensitive bool
+	// DefaultUnit is the multiple used when a number has no symbol (e.g. 1
+	// to read "10" as 10 B). Numbers without symbol are rejected when it is 0.
+	DefaultUnit Byte
+	// ShortSuffixes accepts symbols without their trailing B (e.g. 10k, 10K, 5M, 2Gi)
+	ShortSuffixes bool
+	// Grouping accepts digit grouping separators (e.g. 1 024 B, 1,024 B).
+	// Spaces are always accepted as grouping separator.
+	Grouping bool
+	// Lang defines the decimal and grouping separators (e.g. 1,5 MB in French).
+	// English separators are used when it is nil.
+	Lang *lang.Tag
+}
+
+// ParseError is returned when a quantity cannot be parsed
+type ParseError struct {
+	// Input is the string being parsed
+	Input string
+	// Pos is the zero-based index of the offending character in Input
+	Pos int
+	// Msg describes the problem
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("cannot parse %q at position %d: %s", e.Input, e.Pos, e.Msg)
+}
+
+// Parse parses the string s and returns a Byte
+func (p *ByteParser) Parse(s string) (Byte, error) {
+	n, mag, err := p.scan(s)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return 0, &ParseError{Input: s, Msg: "number out of range"}
+	}
+	return Byte(v) * mag, nil
+}
+
+// ParseCount parses the string s and returns an exact ByteCount.
+// Rounding follows the rules of ParseByteCount.
+func (p *ByteParser) ParseCount(s string) (ByteCount, error) {
+	n, mag, err := p.scan(s)
+	if err != nil {
+		return 0, err
+	}
+
+	r, ok := new(big.Rat).SetString(n)
+	if !ok {
+		return 0, &ParseError{Input: s, Msg: "number out of range"}
+	}
+	r.Mul(r, new(big.Rat).SetInt64(int64(mag)))
+	return ratToCount(r)
+}
+
+// scan splits s into a normalised number (e.g. -1234.5e3), which can be
+// parsed by strconv, and the magnitude of its symbol.
+func (p *ByteParser) scan(s string) (string, Byte, error) {
+	decimal, group := p.separators()
+	sc := numberScanner{decimal: decimal, group: group, grouping: p.Grouping}
+	in := []rune(s)
+	n, i, err := sc.scan(in)
+	if err != nil {
+		err.Input = s
+		return "", 0, err
+	}
+
+	symbol := strings.TrimRightFunc(string(in[i:]), unicode.IsSpace)
+	if symbol == "" {
+		if p.DefaultUnit == 0 {
+			return "", 0, &ParseError{Input: s, Pos: i, Msg: "missing unit"}
+		}
+		return n, p.DefaultUnit, nil
+	}
+	mag, ok := p.magnitude(symbol)
+	if !ok {
+		return "", 0, &ParseError{Input: s, Pos: i, Msg: "unknown unit " + symbol}
+	}
+	return n, mag, nil
+}
+
+// numberScanner reads numbers with configurable separators
+type numberScanner struct {
+	decimal  rune
+	group    rune
+	grouping bool
+}
+
+// scan reads a number from in, skipping the spaces around it. It returns the
+// number normalised so it can be parsed by strconv (e.g. -1234.5e3), and the
+// index of the first rune following it. Errors do not have their Input set.
+func (sc *numberScanner) scan(in []rune) (string, int, *ParseError) {
+	fail := func(pos int, msg string) (string, int, *ParseError) {
+		return "", 0, &ParseError{Pos: pos, Msg: msg}
+	}
+	isGroup := func(r rune) bool {
+		return sc.grouping && (r == sc.group || unicode.IsSpace(r) ||
+			(sc.group == '’' && r == '\''))
+	}
+	isDigit := func(i int) bool {
+		return i < len(in) && '0' <= in[i] && in[i] <= '9'
+	}
+
+	i := 0
+	for i < len(in) && unicode.IsSpace(in[i]) {
+		i++
+	}
+	if i == len(in) {
+		return fail(i, "empty input")
+	}
+
+	var n strings.Builder
+	if in[i] == '-' || in[i] == '+' {
+		n.WriteRune(in[i])
+		i++
+	}
+
+	// Integer part, with optional grouping
+	start, digits, lastGroup := i, 0, -1
+	for i < len(in) {
+		switch {
+		case isDigit(i):
+			n.WriteRune(in[i])
+			digits++
+			i++
+			continue
+		case isGroup(in[i]) && digits > 0 && isDigit(i+1):
+			if lastGroup >= 0 && digits != 3 {
+				return fail(lastGroup, "digits must be grouped by 3")
+			}
+			lastGroup, digits = i, 0
+			i++
+			continue
+		}
+		break
+	}
+	if lastGroup >= 0 && digits != 3 {
+		return fail(lastGroup, "digits must be grouped by 3")
+	}
+
+	// Fractional part
+	if i < len(in) && in[i] == sc.decimal && isDigit(i+1) {
+		n.WriteRune('.')
+		i++
+		for isDigit(i) {
+			n.WriteRune(in[i])
+			i++
+		}
+	} else if i == start {
+		return fail(i, "expect a number")
+	}
+
+	// Exponent, which must be followed by a digit to leave room for EB and EiB
+	if i < len(in) && (in[i] == 'e' || in[i] == 'E') {
+		j := i + 1
+		if j < len(in) && (in[j] == '-' || in[j] == '+') {
+			j++
+		}
+		if isDigit(j) {
+			n.WriteString(string(in[i:j]))
+			for i = j; isDigit(i); i++ {
+				n.WriteRune(in[i])
+			}
+		}
+	}
+
+	for i < len(in) && unicode.IsSpace(in[i]) {
+		i++
+	}
+	return n.String(), i, nil
+}
+
+// magnitude resolves symbol according to the parser leniency
+func (p *ByteParser) magnitude(symbol string) (Byte, bool) {
+	if mag, ok := p.System.magnitude(symbol); ok {
+		return mag, true
+	}
+	if !p.ShortSuffixes && !p.CaseInsensitive {
+		return 0, false
+	}
+
+	match := func(candidate string) bool {
+		if p.CaseInsensitive {
+			return strings.EqualFold(candidate, symbol)
+		}
+		return candidate == symbol
+	}
+	scales := append([]scale{{"B", 1}}, iecScales...)
+	if p.System == Legacy {
+		scales = append(scales, legacyScales...)
+	} else {
+		scales = append(scales, siScales...)
+	}
+	for _, sc := range scales {
+		if match(sc.symbol) {
+			return sc.mag, true
+		}
+		if !p.ShortSuffixes || sc.symbol == "B" {
+			continue
+		}
+		short := strings.TrimSuffix(sc.symbol, "B")
+		if match(short) || (short == "k" && match("K")) {
+			return sc.mag, true
+		}
+	}
+	return 0, false
+}
+
+// separators returns the decimal and grouping separators of the parser language
+func (p *ByteParser) separators() (decimal, group rune) {
+	if p.Lang == nil {
+		return '.', ','
+	}
+	return separators(p.Lang.Translator())
+}
+
+// separators returns the decimal and grouping separators of a locale
+func separators(tr locales.Translator) (decimal, group rune) {
+	// Extract separators from a formatted sample (e.g. 1’234.5, 1 234,5)
+	decimal, group = '.', ','
+	for i, r := range []rune(tr.FmtNumber(1234.5, 1)) {
+		if '0' <= r && r <= '9' {
+			continue
+		}
+		if i == 1 {
+			group = r
+		} else {
+			decimal = r
+		}
+	}
+	if group == decimal {
+		group = '.'
+	}
+	return decimal, group
+}
@@ -0,0 +1,106 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/deixis/pkg/lang"
+	"github.com/deixis/pkg/unit"
+)
+
+func TestByteParser(t *testing.T) {
+	t.Parallel()
+
+	lenient := unit.ByteParser{
+		CaseInsensitive: true,
+		DefaultUnit:     1,
+		ShortSuffixes:   true,
+		Grouping:        true,
+	}
+	french := lenient
+	french.Lang = &lang.SwissFrench
+	swissGerman := lenient
+	swissGerman.Lang = &lang.SwissGerman
+
+	table := []struct {
+		parser unit.ByteParser
+		in     string
+		expect unit.Byte
+		pos    int
+		err    bool
+	}{
+		// Strict
+		{in: "1B", expect: 1},
+		{in: "10B", expect: 10},
+		{in: "3.3kB", expect: unit.KB * 3.3},
+		{in: " -1 TB ", expect: -unit.TB},
+		{in: "+2 KiB", expect: unit.KiB * 2},
+		{in: ".5 kB", expect: unit.KB / 2},
+		{in: "1e3 B", expect: 1000},
+		{in: "1EiB", expect: unit.EiB},
+		{in: "1EB", err: true, pos: 1},
+		{in: "10kb", err: true, pos: 2},
+		{in: "10k", err: true, pos: 2},
+		{in: "10", err: true, pos: 2},
+		{in: "1 024 B", err: true, pos: 2},
+		{in: "", err: true, pos: 0},
+		{in: "kB", err: true, pos: 0},
+		{in: "1.2.3 kB", err: true, pos: 3},
+
+		// Lenient
+		{parser: lenient, in: "10kb", expect: unit.KB * 10},
+		{parser: lenient, in: "10 KB", expect: unit.KB * 10},
+		{parser: lenient, in: "10k", expect: unit.KB * 10},
+		{parser: lenient, in: "10K", expect: unit.KB * 10},
+		{parser: lenient, in: "5 mib", expect: unit.MiB * 5},
+		{parser: lenient, in: "2Gi", expect: unit.GiB * 2},
+		{parser: lenient, in: "10", expect: 10},
+		{parser: lenient, in: "1 024 B", expect: 1024},
+		{parser: lenient, in: "1,048,576", expect: 1048576},
+		{parser: lenient, in: "1,5 MB", err: true, pos: 1},
+		{parser: lenient, in: "1,0000 MB", err: true, pos: 1},
+		{parser: lenient, in: "10 XB", err: true, pos: 3},
+		{parser: unit.ByteParser{System: unit.SI, ShortSuffixes: true}, in: "10k", expect: 10000},
+
+		// Localised
+		{parser: french, in: "1,5 MB", expect: unit.MB * 1.5},
+		{parser: french, in: "1 024,5 B", expect: 1024.5},
+		{parser: french, in: "1 024 B", expect: 1024},
+		{parser: swissGerman, in: "1’024.5 B", expect: 1024.5},
+		{parser: swissGerman, in: "1'024 B", expect: 1024},
+	}
+
+	for i, test := range table {
+		res, err := test.parser.Parse(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			perr, ok := err.(*unit.ParseError)
+			if !ok {
+				t.Errorf("#%d - expect a ParseError, but got %T", i, err)
+				continue
+			}
+			if perr.Pos != test.pos {
+				t.Errorf("#%d - expect error at %d, but got %d (%s)", i, test.pos, perr.Pos, err)
+			}
+			continue
+		}
+
+		if res != test.expect {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+	}
+}
+
+func TestByteParserCount(t *testing.T) {
+	t.Parallel()
+
+	p := unit.ByteParser{System: unit.SI, Grouping: true}
+	res, err := p.ParseCount("9 007 199 254 740 993 B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != 9007199254740993 {
+		t.Errorf("expect to get 9007199254740993, but got %d", res)
+	}
+}
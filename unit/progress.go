@@ -0,0 +1,227 @@
+package unit
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressWindow is the time window of the throughput moving average
+const progressWindow = 5 * time.Second
+
+// ProgressReport is a snapshot of the progress of a transfer
+type ProgressReport struct {
+	// Transferred is the amount of bytes transferred so far
+	Transferred Byte
+	// Total is the expected size of the transfer, or 0 when unknown
+	Total Byte
+	// Rate is the moving average of the throughput
+	Rate ByteRate
+	// Elapsed is the time elapsed since the beginning of the transfer
+	Elapsed time.Duration
+	// ETA is the estimated remaining time, or -1 when unknown
+	ETA time.Duration
+	// Done is set on the last report, once the progress is closed
+	Done bool
+}
+
+// Percent returns the completion percentage, or -1 when the total is unknown
+func (r ProgressReport) Percent() float64 {
+	if r.Total <= 0 {
+		return -1
+	}
+	return math.Min(float64(r.Transferred/r.Total)*100, 100)
+}
+
+// String returns a human-readable report, with amounts rounded for display
+//
+// e.g. 3 MB / 10 MB (30%) at 1.5 MB/s, 4s left
+func (r ProgressReport) String() string {
+	s := r.Transferred.Display()
+	if r.Total > 0 {
+		s += fmt.Sprintf(" / %s (%.0f%%)", r.Total.Display(), r.Percent())
+	}
+	s += " at " + r.Rate.String()
+	if r.ETA >= 0 && !r.Done {
+		s += ", " + r.ETA.Round(time.Second).String() + " left"
+	}
+	return s
+}
+
+// Progress tracks the progress of a transfer
+//
+// Reports are delivered at a regular interval to the callback, and on the
+// Updates channel, until the progress is closed.
+type Progress struct {
+	transferred int64 // atomic
+
+	total    Byte
+	interval time.Duration
+	fn       func(ProgressReport)
+	updates  chan ProgressReport
+	start    time.Time
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	rate     float64
+	lastN    int64
+	lastTime time.Time
+}
+
+// NewProgress starts tracking a transfer of total bytes (0 when unknown), and
+// reports its progress every interval (1s when not positive) to fn (which
+// may be nil). Close must be called once the transfer is over.
+func NewProgress(total Byte, interval time.Duration, fn func(ProgressReport)) *Progress {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	now := time.Now()
+	p := &Progress{
+		total:    total,
+		interval: interval,
+		fn:       fn,
+		updates:  make(chan ProgressReport, 1),
+		start:    now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		lastTime: now,
+	}
+	go p.run()
+	return p
+}
+
+// Updates returns a channel which receives the latest report at each
+// interval. Stale reports are dropped when the channel is not drained, and
+// the channel is closed after the final report.
+func (p *Progress) Updates() <-chan ProgressReport {
+	return p.updates
+}
+
+// Add records n transferred bytes
+func (p *Progress) Add(n int) {
+	atomic.AddInt64(&p.transferred, int64(n))
+}
+
+// Report returns the current progress
+func (p *Progress) Report() ProgressReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.report(time.Now())
+}
+
+// Close stops tracking the transfer and delivers a final report
+func (p *Progress) Close() error {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+	return nil
+}
+
+// Reader returns a Reader which records the bytes read from r
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+// Writer returns a Writer which records the bytes written to w
+func (p *Progress) Writer(w io.Writer) io.Writer {
+	return &progressWriter{w: w, p: p}
+}
+
+func (p *Progress) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			p.mu.Lock()
+			p.sample(now)
+			r := p.report(now)
+			p.mu.Unlock()
+			p.deliver(r)
+		case <-p.stop:
+			now := time.Now()
+			p.mu.Lock()
+			p.sample(now)
+			r := p.report(now)
+			p.mu.Unlock()
+			r.Done = true
+			r.ETA = 0
+			p.deliver(r)
+			close(p.updates)
+			return
+		}
+	}
+}
+
+// sample updates the throughput moving average
+func (p *Progress) sample(now time.Time) {
+	dt := now.Sub(p.lastTime)
+	if dt <= 0 {
+		return
+	}
+	n := atomic.LoadInt64(&p.transferred)
+	inst := float64(n-p.lastN) / dt.Seconds()
+	if p.lastTime.Equal(p.start) {
+		p.rate = inst
+	} else {
+		alpha := 1 - math.Exp(-float64(dt)/float64(progressWindow))
+		p.rate = alpha*inst + (1-alpha)*p.rate
+	}
+	p.lastN, p.lastTime = n, now
+}
+
+func (p *Progress) report(now time.Time) ProgressReport {
+	r := ProgressReport{
+		Transferred: Byte(atomic.LoadInt64(&p.transferred)),
+		Total:       p.total,
+		Rate:        ByteRate(p.rate),
+		Elapsed:     now.Sub(p.start),
+		ETA:         -1,
+	}
+	if r.Total > 0 && r.Rate > 0 {
+		r.ETA = r.Rate.TransferTime(r.Total - r.Transferred)
+	}
+	return r
+}
+
+func (p *Progress) deliver(r ProgressReport) {
+	if p.fn != nil {
+		p.fn(r)
+	}
+	// Replace the stale report, if any
+	select {
+	case <-p.updates:
+	default:
+	}
+	p.updates <- r
+}
+
+type progressReader struct {
+	r io.Reader
+	p *Progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.Add(n)
+	return n, err
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *Progress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.p.Add(n)
+	return n, err
+}
@@ -0,0 +1,124 @@
+package unit_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var reports []unit.ProgressReport
+	p := unit.NewProgress(4*unit.KB, 10*time.Millisecond, func(r unit.ProgressReport) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+
+	r := p.Reader(bytes.NewReader(make([]byte, 4096)))
+	for i := 0; i < 4; i++ {
+		if _, err := io.CopyN(ioutil.Discard, r, 1024); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(15 * time.Millisecond)
+	}
+	if got := p.Report().Transferred; got != 4*unit.KB {
+		t.Errorf("expect to get %s, but got %s", 4*unit.KB, got)
+	}
+	p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 2 {
+		t.Fatalf("expect to get several reports, but got %d", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Transferred != 4*unit.KB || last.Percent() != 100 {
+		t.Errorf("unexpected final report %+v", last)
+	}
+	for i, r := range reports[:len(reports)-1] {
+		if r.Done {
+			t.Errorf("#%d - expect report not to be final", i)
+		}
+		if r.Rate > 0 && r.ETA < 0 {
+			t.Errorf("#%d - expect an ETA with a known rate", i)
+		}
+	}
+}
+
+func TestProgressUpdates(t *testing.T) {
+	t.Parallel()
+
+	p := unit.NewProgress(0, time.Millisecond, nil)
+	w := p.Writer(ioutil.Discard)
+	w.Write(make([]byte, 100))
+	time.Sleep(5 * time.Millisecond)
+	p.Close()
+
+	var last unit.ProgressReport
+	for r := range p.Updates() {
+		last = r
+	}
+	if !last.Done || last.Transferred != 100 {
+		t.Errorf("unexpected final report %+v", last)
+	}
+	if last.Percent() != -1 || last.ETA != 0 {
+		t.Errorf("expect unknown completion, but got %+v", last)
+	}
+}
+
+// TestProgressDefaultInterval ensures that a non-positive interval falls
+// back to the default one
+func TestProgressDefaultInterval(t *testing.T) {
+	t.Parallel()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		p := unit.NewProgress(10, interval, nil)
+		p.Add(10)
+		p.Close()
+
+		var last unit.ProgressReport
+		for r := range p.Updates() {
+			last = r
+		}
+		if !last.Done || last.Transferred != 10 {
+			t.Errorf("%s - unexpected final report %+v", interval, last)
+		}
+	}
+}
+
+func TestProgressReportString(t *testing.T) {
+	t.Parallel()
+
+	r := unit.ProgressReport{
+		Transferred: 3 * unit.MB,
+		Total:       10 * unit.MB,
+		Rate:        unit.ByteRate(1.5 * unit.DecimalMB),
+		ETA:         4*time.Second + 600*time.Millisecond,
+	}
+	if got := r.String(); got != "3 MB / 10 MB (30%) at 1.5 MB/s, 5s left" {
+		t.Errorf("unexpected report %s", got)
+	}
+
+	r = unit.ProgressReport{
+		Transferred: 3*unit.MB + 12345,
+		Total:       10*unit.MB + 6789,
+		Rate:        unit.ByteRate(1234567),
+		ETA:         -1,
+	}
+	if got := r.String(); got != "3.01 MB / 10 MB (30%) at 1.234567 MB/s" {
+		t.Errorf("unexpected report %s", got)
+	}
+
+	r = unit.ProgressReport{Transferred: 3 * unit.MB, ETA: -1}
+	if got := r.String(); got != "3 MB at 0 B/s" {
+		t.Errorf("unexpected report %s", got)
+	}
+}
@@ -0,0 +1,209 @@
+package unit
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/deixis/errors"
+)
+
+// ErrQuotaExceeded is returned when there is not enough space in a quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrInvalidReservation is returned when the size of a reservation is
+// negative or NaN
+var ErrInvalidReservation = errors.New("invalid reservation size")
+
+// Quota tracks the usage of a byte capacity across goroutines
+//
+// Space is first reserved, then either committed once the data is stored, or
+// cancelled. Committed space is given back with Release (e.g. once the data
+// is deleted).
+//
+// Usage is tracked in whole bytes, so it does not drift. Reservations and
+// releases are rounded up to the next byte, and the capacity down.
+type Quota struct {
+	mu       sync.Mutex
+	capacity ByteCount
+	used     ByteCount
+	reserved ByteCount
+	// freed is closed (and replaced) whenever space becomes available
+	freed chan struct{}
+}
+
+// QuotaUsage is a snapshot of the usage of a quota
+type QuotaUsage struct {
+	Capacity  Byte
+	Used      Byte
+	Reserved  Byte
+	Available Byte
+}
+
+// NewQuota returns a Quota with the given capacity
+func NewQuota(capacity Byte) *Quota {
+	return &Quota{
+		capacity: toCount(capacity, math.Floor),
+		freed:    make(chan struct{}),
+	}
+}
+
+// TryReserve reserves n bytes without blocking. It returns ErrQuotaExceeded
+// when there is not enough space available.
+func (q *Quota) TryReserve(n Byte) (*Reservation, error) {
+	if !validReservation(n) {
+		return nil, ErrInvalidReservation
+	}
+
+	c := toCount(n, math.Ceil)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if c > q.available() {
+		return nil, ErrQuotaExceeded
+	}
+	q.reserved += c
+	return &Reservation{q: q, n: c}, nil
+}
+
+// Reserve reserves n bytes, and blocks until enough space is available or
+// ctx is done. It returns ErrQuotaExceeded straight away when n exceeds the
+// capacity.
+func (q *Quota) Reserve(ctx context.Context, n Byte) (*Reservation, error) {
+	if !validReservation(n) {
+		return nil, ErrInvalidReservation
+	}
+
+	c := toCount(n, math.Ceil)
+	for {
+		q.mu.Lock()
+		if c > q.capacity {
+			q.mu.Unlock()
+			return nil, ErrQuotaExceeded
+		}
+		if c <= q.available() {
+			q.reserved += c
+			q.mu.Unlock()
+			return &Reservation{q: q, n: c}, nil
+		}
+		freed := q.freed
+		q.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release gives back n committed bytes. Negative and NaN sizes are ignored.
+func (q *Quota) Release(n Byte) {
+	if !validReservation(n) {
+		return
+	}
+
+	c := toCount(n, math.Ceil)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.used -= c
+	if q.used < 0 {
+		q.used = 0
+	}
+	q.notify()
+}
+
+// SetCapacity changes the capacity of the quota. Existing usage is kept, even
+// when it exceeds the new capacity.
+func (q *Quota) SetCapacity(capacity Byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.capacity = toCount(capacity, math.Floor)
+	q.notify()
+}
+
+// Usage returns a snapshot of the usage of the quota
+func (q *Quota) Usage() QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QuotaUsage{
+		Capacity:  q.capacity.Byte(),
+		Used:      q.used.Byte(),
+		Reserved:  q.reserved.Byte(),
+		Available: q.available().Byte(),
+	}
+}
+
+func (q *Quota) available() ByteCount {
+	if a := q.capacity - q.used - q.reserved; a > 0 {
+		return a
+	}
+	return 0
+}
+
+// validReservation returns whether n bytes can be reserved or released
+func validReservation(n Byte) bool {
+	return n >= 0 // false for NaN
+}
+
+// toCount rounds n to whole bytes, saturating at the largest ByteCount
+func toCount(n Byte, round func(float64) float64) ByteCount {
+	v := round(float64(n))
+	if v >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if v <= math.MinInt64 {
+		return math.MinInt64
+	}
+	return ByteCount(v)
+}
+
+// notify wakes up the goroutines waiting for space
+func (q *Quota) notify() {
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// Reservation is space reserved in a quota. It must be either committed or
+// cancelled.
+type Reservation struct {
+	q    *Quota
+	n    ByteCount
+	done bool
+}
+
+// Size returns the reserved space, rounded up to the next byte
+func (r *Reservation) Size() Byte {
+	return r.n.Byte()
+}
+
+// Commit turns the reservation into used space. It has no effect when the
+// reservation has already been committed or cancelled.
+func (r *Reservation) Commit() {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	r.q.reserved -= r.n
+	r.q.used += r.n
+}
+
+// Cancel gives back the reserved space. It has no effect when the
+// reservation has already been committed or cancelled.
+func (r *Reservation) Cancel() {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	r.q.reserved -= r.n
+	r.q.notify()
+}
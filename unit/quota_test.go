@@ -0,0 +1,167 @@
+package unit_test
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+
+	q := unit.NewQuota(10 * unit.MB)
+	r1, err := q.TryReserve(6 * unit.MB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.TryReserve(5 * unit.MB); err != unit.ErrQuotaExceeded {
+		t.Errorf("expect to get %s, but got %v", unit.ErrQuotaExceeded, err)
+	}
+	r2, err := q.TryReserve(4 * unit.MB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r1.Commit()
+	r1.Commit()
+	r2.Cancel()
+	r2.Commit()
+
+	expect := unit.QuotaUsage{
+		Capacity:  10 * unit.MB,
+		Used:      6 * unit.MB,
+		Reserved:  0,
+		Available: 4 * unit.MB,
+	}
+	if got := q.Usage(); got != expect {
+		t.Errorf("expect to get %+v, but got %+v", expect, got)
+	}
+
+	q.Release(2 * unit.MB)
+	if got := q.Usage().Available; got != 6*unit.MB {
+		t.Errorf("expect to get 6 MB available, but got %s", got)
+	}
+	q.Release(10 * unit.MB)
+	if got := q.Usage().Used; got != 0 {
+		t.Errorf("expect to get nothing used, but got %s", got)
+	}
+}
+
+// TestQuotaFractional ensures that fractional sizes do not make the usage
+// drift
+func TestQuotaFractional(t *testing.T) {
+	t.Parallel()
+
+	q := unit.NewQuota(unit.KB)
+	r1, err := q.TryReserve(0.1 * unit.DecimalKB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := q.TryReserve(0.2 * unit.KB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.Size() != 205 {
+		t.Errorf("expect to reserve 205 B, but got %s", r2.Size())
+	}
+	r1.Cancel()
+	r2.Cancel()
+
+	expect := unit.QuotaUsage{Capacity: unit.KB, Available: unit.KB}
+	if got := q.Usage(); got != expect {
+		t.Errorf("expect to get %+v, but got %+v", expect, got)
+	}
+}
+
+// TestQuotaInvalidSize ensures that negative and NaN sizes cannot inflate
+// a quota
+func TestQuotaInvalidSize(t *testing.T) {
+	t.Parallel()
+
+	q := unit.NewQuota(unit.Byte(10))
+	r, err := q.TryReserve(unit.Byte(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Commit()
+
+	for _, n := range []unit.Byte{-100, unit.Byte(math.NaN())} {
+		if _, err := q.TryReserve(n); err != unit.ErrInvalidReservation {
+			t.Errorf("%s - expect to get %s, but got %v", n, unit.ErrInvalidReservation, err)
+		}
+		if _, err := q.Reserve(context.Background(), n); err != unit.ErrInvalidReservation {
+			t.Errorf("%s - expect to get %s, but got %v", n, unit.ErrInvalidReservation, err)
+		}
+		q.Release(n)
+	}
+
+	expect := unit.QuotaUsage{Capacity: unit.Byte(10), Used: unit.Byte(6), Available: unit.Byte(4)}
+	if got := q.Usage(); got != expect {
+		t.Errorf("expect to get %+v, but got %+v", expect, got)
+	}
+}
+
+func TestQuotaReserveBlocking(t *testing.T) {
+	t.Parallel()
+
+	q := unit.NewQuota(10 * unit.MB)
+	r, err := q.TryReserve(8 * unit.MB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Never fits
+	if _, err := q.Reserve(context.Background(), 11*unit.MB); err != unit.ErrQuotaExceeded {
+		t.Errorf("expect to get %s, but got %v", unit.ErrQuotaExceeded, err)
+	}
+
+	// Times out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Reserve(ctx, 5*unit.MB); err != context.DeadlineExceeded {
+		t.Errorf("expect to get %s, but got %v", context.DeadlineExceeded, err)
+	}
+
+	// Unblocked once space is freed
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Cancel()
+	}()
+	if _, err := q.Reserve(context.Background(), 5*unit.MB); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaConcurrency(t *testing.T) {
+	t.Parallel()
+
+	q := unit.NewQuota(100 * unit.KB)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				r, err := q.Reserve(context.Background(), 10*unit.KB)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if u := q.Usage(); u.Used+u.Reserved > u.Capacity {
+					t.Errorf("quota overcommitted %+v", u)
+				}
+				r.Commit()
+				q.Release(10 * unit.KB)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if u := q.Usage(); u.Used != 0 || u.Reserved != 0 {
+		t.Errorf("expect empty quota, but got %+v", u)
+	}
+}
@@ -0,0 +1,272 @@
+package unit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deixis/errors"
+)
+
+// BitRate represents a throughput in bits per second
+type BitRate float64
+
+// ByteRate represents a throughput in bytes per second
+type ByteRate float64
+
+const (
+	// Bps means bit per second
+	Bps BitRate = 1
+	// Kbps means kilobit per second (1000 bits)
+	Kbps BitRate = 1e3
+	// Mbps means megabit per second (1000 kbit)
+	Mbps BitRate = 1e6
+	// Gbps means gigabit per second (1000 Mbit)
+	Gbps BitRate = 1e9
+	// Tbps means terabit per second (1000 Gbit)
+	Tbps BitRate = 1e12
+)
+
+// Bit rates are always formatted with decimal prefixes
+var bitScales = []struct {
+	symbol string
+	mag    BitRate
+}{
+	{"Tbps", Tbps}, {"Gbps", Gbps}, {"Mbps", Mbps}, {"kbps", Kbps},
+}
+
+// bitPrefixes maps the prefixes accepted in front of "b" or "bit"
+var bitPrefixes = map[string]float64{
+	"":   1,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// ParseBitRate parses a throughput expressed either in bits or in bytes
+// per duration, and returns it in bits per second.
+//
+// e.g. 100 Mbps, 1 Gbit/s, 12.5 MB/s, 3 GiB/h, 100 MB/30s, 10 kBps
+//
+// Throughputs follow the networking convention, so decimal byte symbols
+// (kB, MB, ...) are decimal multiples (SI).
+func ParseBitRate(s string) (BitRate, error) {
+	bits, err := parseRate(s)
+	if err != nil {
+		return 0, err
+	}
+	return BitRate(bits), nil
+}
+
+// ParseByteRate parses a throughput expressed either in bits or in bytes
+// per duration, and returns it in bytes per second.
+//
+// e.g. 12.5 MB/s, 3 GiB/h, 100 MB/30s, 10 kBps, 100 Mbps, 1 Gbit/s
+//
+// Throughputs follow the networking convention, so decimal byte symbols
+// (kB, MB, ...) are decimal multiples (SI).
+func ParseByteRate(s string) (ByteRate, error) {
+	bits, err := parseRate(s)
+	if err != nil {
+		return 0, err
+	}
+	return BitRate(bits).ByteRate(), nil
+}
+
+// ByteRateOf returns the throughput of transferring b in d.
+// It returns 0 when d is not positive.
+func ByteRateOf(b Byte, d time.Duration) ByteRate {
+	if d <= 0 {
+		return 0
+	}
+	return ByteRate(float64(b) / d.Seconds())
+}
+
+// ByteRate converts r to bytes per second
+func (r BitRate) ByteRate() ByteRate {
+	return ByteRate(r / 8)
+}
+
+// TransferTime returns how long it takes to transfer b at the rate r
+func (r BitRate) TransferTime(b Byte) time.Duration {
+	return r.ByteRate().TransferTime(b)
+}
+
+func (r BitRate) String() string {
+	for _, sc := range bitScales {
+		if math.Abs(float64(r/sc.mag)) >= 1 {
+			return strconv.FormatFloat(float64(r/sc.mag), 'f', -1, 64) + " " + sc.symbol
+		}
+	}
+	return strconv.FormatFloat(float64(r), 'f', -1, 64) + " bps"
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (r BitRate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *BitRate) UnmarshalJSON(data []byte) error {
+	v, err := ParseBitRate(strings.Trim(string(data), "\""))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (r BitRate) MarshalText() (text []byte, err error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (r *BitRate) UnmarshalText(text []byte) error {
+	v, err := ParseBitRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// BitRate converts r to bits per second
+func (r ByteRate) BitRate() BitRate {
+	return BitRate(r * 8)
+}
+
+// Per returns the amount of bytes transferred in d at the rate r
+func (r ByteRate) Per(d time.Duration) Byte {
+	return Byte(float64(r) * d.Seconds())
+}
+
+// TransferTime returns how long it takes to transfer b at the rate r.
+// It returns the maximum duration when the transfer never completes.
+func (r ByteRate) TransferTime(b Byte) time.Duration {
+	if b <= 0 {
+		return 0
+	}
+	if r <= 0 {
+		return math.MaxInt64
+	}
+	sec := float64(b) / float64(r)
+	if sec >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+// String returns the representation of r in SI units per second
+func (r ByteRate) String() string {
+	return SI.Format(Byte(r)) + "/s"
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (r ByteRate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *ByteRate) UnmarshalJSON(data []byte) error {
+	v, err := ParseByteRate(strings.Trim(string(data), "\""))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (r ByteRate) MarshalText() (text []byte, err error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (r *ByteRate) UnmarshalText(text []byte) error {
+	v, err := ParseByteRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// parseRate parses s and returns its value in bits per second
+func parseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	// Split the quantity from its period (e.g. MB/s, Mbps)
+	var quantity string
+	var period time.Duration
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		p := strings.TrimSpace(s[i+1:])
+		if p == "min" {
+			p = "m"
+		}
+		// A period without a number is a single unit (e.g. MB/s)
+		if p != "" && isLetter(p[0]) {
+			p = "1" + p
+		}
+		d, err := time.ParseDuration(p)
+		if err != nil || p == "" {
+			return 0, fmt.Errorf("unknown period %s", p)
+		}
+		if d <= 0 {
+			return 0, fmt.Errorf("invalid period %s", p)
+		}
+		quantity, period = s[:i], d
+	} else if strings.HasSuffix(s, "ps") {
+		quantity, period = s[:len(s)-2], time.Second
+	} else {
+		return 0, fmt.Errorf("invalid format")
+	}
+
+	// Split the number from its symbol
+	quantity = strings.TrimSpace(quantity)
+	i := len(quantity)
+	for i > 0 && isLetter(quantity[i-1]) {
+		i--
+	}
+	n, symbol := strings.TrimSpace(quantity[:i]), quantity[i:]
+
+	var mag float64
+	switch {
+	case strings.HasSuffix(symbol, "B"):
+		m, ok := SI.magnitude(symbol)
+		if !ok {
+			return 0, fmt.Errorf("unknown scale %s", symbol)
+		}
+		mag = float64(m) * 8
+	case strings.HasSuffix(symbol, "bit"), strings.HasSuffix(symbol, "b"):
+		prefix := strings.TrimSuffix(strings.TrimSuffix(symbol, "it"), "b")
+		m, ok := bitPrefixes[prefix]
+		if !ok {
+			return 0, fmt.Errorf("unknown scale %s", symbol)
+		}
+		mag = m
+	default:
+		return 0, fmt.Errorf("unknown scale %s", symbol)
+	}
+
+	v, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid number")
+	}
+	return v * mag / period.Seconds(), nil
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
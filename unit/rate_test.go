@@ -0,0 +1,128 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestParseRate(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		in     string
+		expect unit.ByteRate
+		err    bool
+	}{
+		{in: "100 Mbps", expect: 12.5e6},
+		{in: "100Mbps", expect: 12.5e6},
+		{in: "1 Gbit/s", expect: 125e6},
+		{in: "8 bit/s", expect: 1},
+		{in: "8 bps", expect: 1},
+		{in: "12.5 MB/s", expect: 12.5e6},
+		{in: "10 kBps", expect: 10e3},
+		{in: "1 MiB/s", expect: 1 << 20},
+		{in: "8 Kibit/s", expect: 1024},
+		{in: "3.6 GB/h", expect: 1e6},
+		{in: "60 kB/min", expect: 1e3},
+		{in: "1 B/ms", expect: 1e3},
+		{in: "100 MB/30s", expect: 100e6 / 30},
+		{in: "1 GB/5m", expect: 1e9 / 300},
+		{in: "1 kB/1.5s", expect: 1e3 / 1.5},
+		{in: "1 MB/0s", err: true},
+		{in: "1 MB/-5m", err: true},
+		{in: "1 MB", err: true},
+		{in: "1 MB/", err: true},
+		{in: "1 MB/week", err: true},
+		{in: "1 Mx/s", err: true},
+		{in: "1 Xb/s", err: true},
+		{in: "abc Mbps", err: true},
+	}
+
+	for i, test := range table {
+		res, err := unit.ParseByteRate(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("#%d - expect error %t, but got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if math.Abs(float64(res-test.expect)) > 1e-6 {
+			t.Errorf("#%d - expect to get %f, but got %f", i, test.expect, res)
+		}
+
+		bits, err := unit.ParseBitRate(test.in)
+		if err != nil {
+			t.Errorf("#%d - unexpected error %s", i, err)
+		}
+		if math.Abs(float64(bits.ByteRate()-res)) > 1e-6 {
+			t.Errorf("#%d - expect bit rate %f to match byte rate %f", i, bits, res)
+		}
+	}
+}
+
+func TestRateString(t *testing.T) {
+	t.Parallel()
+
+	if got := (100 * unit.Mbps).String(); got != "100 Mbps" {
+		t.Errorf("expect to get 100 Mbps, but got %s", got)
+	}
+	if got := unit.BitRate(500).String(); got != "500 bps" {
+		t.Errorf("expect to get 500 bps, but got %s", got)
+	}
+	if got := unit.ByteRate(12.5e6).String(); got != "12.5 MB/s" {
+		t.Errorf("expect to get 12.5 MB/s, but got %s", got)
+	}
+	if got := (unit.Gbps).ByteRate().String(); got != "125 MB/s" {
+		t.Errorf("expect to get 125 MB/s, but got %s", got)
+	}
+}
+
+func TestRateTransfer(t *testing.T) {
+	t.Parallel()
+
+	r := unit.ByteRate(unit.MiB)
+	if got := r.TransferTime(unit.MiB * 90); got != 90*time.Second {
+		t.Errorf("expect to get 90s, but got %s", got)
+	}
+	if got := (8 * unit.Mbps).TransferTime(unit.DecimalMB); got != time.Second {
+		t.Errorf("expect to get 1s, but got %s", got)
+	}
+	if got := unit.ByteRate(0).TransferTime(unit.KB); got != math.MaxInt64 {
+		t.Errorf("expect to never complete, but got %s", got)
+	}
+	if got := r.Per(time.Minute); got != unit.MiB*60 {
+		t.Errorf("expect to get 60 MiB, but got %s", got.Format(unit.IEC))
+	}
+	if got := unit.ByteRateOf(unit.MiB*30, 30*time.Second); got != r {
+		t.Errorf("expect to get %s, but got %s", r, got)
+	}
+}
+
+func TestRateJSON(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Limit unit.BitRate  `json:"limit"`
+		Repl  unit.ByteRate `json:"repl"`
+	}
+
+	in := config{Limit: 100 * unit.Mbps, Repl: 12.5e6}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"limit":"100 Mbps","repl":"12.5 MB/s"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if in != out {
+		t.Errorf("expect to get %v, but got %v", in, out)
+	}
+}
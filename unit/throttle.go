@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Throttle is a token bucket which limits a throughput
+//
+// The bucket fills up at a constant rate, up to a burst size. A Throttle can
+// be shared by several streams, in which case they share its budget.
+type Throttle struct {
+	mu     sync.Mutex
+	rate   ByteRate
+	burst  float64 // 0 for one second of rate
+	tokens float64
+	last   time.Time
+}
+
+// NewThrottle returns a Throttle which allows a sustained throughput of rate,
+// with bursts of up to burst bytes. A non-positive rate disables throttling,
+// and a burst smaller than 1 byte defaults to one second of rate.
+func NewThrottle(rate ByteRate, burst Byte) *Throttle {
+	t := &Throttle{rate: rate}
+	if burst >= 1 {
+		t.burst = float64(burst)
+	}
+	t.tokens = t.size()
+	return t
+}
+
+// Rate returns the current throughput limit
+func (t *Throttle) Rate() ByteRate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate
+}
+
+// SetRate changes the throughput limit. Streams already waiting keep their
+// current schedule.
+func (t *Throttle) SetRate(rate ByteRate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refill()
+	if t.rate <= 0 {
+		// Throttling starts with a full bucket
+		t.tokens = math.MaxFloat64
+	}
+	t.rate = rate
+	t.tokens = math.Min(t.tokens, t.size())
+}
+
+// Burst returns the maximum number of bytes transferred at once
+func (t *Throttle) Burst() Byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Byte(t.size())
+}
+
+// size returns the capacity of the bucket
+func (t *Throttle) size() float64 {
+	if t.burst > 0 {
+		return t.burst
+	}
+	return math.Max(float64(t.rate), 1)
+}
+
+// WaitN blocks until n bytes can be transferred, or ctx is done.
+//
+// Requests larger than the burst size are allowed, but they delay the
+// following requests accordingly.
+func (t *Throttle) WaitN(ctx context.Context, n int) error {
+	t.mu.Lock()
+	if t.rate <= 0 || n <= 0 {
+		t.mu.Unlock()
+		return ctx.Err()
+	}
+	t.refill()
+	t.tokens -= float64(n)
+	wait := time.Duration(0)
+	if t.tokens < 0 {
+		sec := -t.tokens / float64(t.rate)
+		wait = time.Duration(math.Min(sec*float64(time.Second), math.MaxInt64))
+	}
+	t.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the tokens, since nothing has been transferred
+		t.mu.Lock()
+		t.tokens = math.Min(t.tokens+float64(n), t.size())
+		t.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens accumulated since the last call
+func (t *Throttle) refill() {
+	now := time.Now()
+	if !t.last.IsZero() && t.rate > 0 {
+		t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+		t.tokens = math.Min(t.tokens, t.size())
+	}
+	t.last = now
+}
+
+// chunk returns the number of bytes to transfer at once out of n. Transfers
+// are not split when throttling is disabled.
+func (t *Throttle) chunk(n int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate <= 0 {
+		return n
+	}
+	if size := t.size(); float64(n) > size {
+		return int(size)
+	}
+	return n
+}
+
+// Reader returns a Reader whose throughput is limited by t
+func (t *Throttle) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &throttledReader{ctx: ctx, r: r, t: t}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	t   *Throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p[:r.t.chunk(len(p))])
+	if werr := r.t.WaitN(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// Writer returns a Writer whose throughput is limited by t
+func (t *Throttle) Writer(ctx context.Context, w io.Writer) io.Writer {
+	return &throttledWriter{ctx: ctx, w: w, t: t}
+}
+
+type throttledWriter struct {
+	ctx context.Context
+	w   io.Writer
+	t   *Throttle
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := w.t.chunk(len(p))
+		if err := w.t.WaitN(w.ctx, chunk); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
@@ -0,0 +1,116 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deixis/pkg/unit"
+)
+
+func TestThrottleReader(t *testing.T) {
+	t.Parallel()
+
+	// 10 kB/s with a 1 kB burst: 3 kB takes ~200ms after the initial burst
+	th := unit.NewThrottle(10*unit.ByteRate(unit.DecimalKB), unit.DecimalKB)
+	data := bytes.Repeat([]byte("a"), 3000)
+
+	start := time.Now()
+	got, err := ioutil.ReadAll(th.Reader(context.Background(), bytes.NewReader(data)))
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("expect to read all data")
+	}
+	if elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expect to take ~200ms, but took %s", elapsed)
+	}
+}
+
+func TestThrottleWriterShared(t *testing.T) {
+	t.Parallel()
+
+	// Two streams share a budget of 20 kB/s, so 2 x 3 kB takes ~250ms
+	th := unit.NewThrottle(20*unit.ByteRate(unit.DecimalKB), unit.DecimalKB)
+	data := bytes.Repeat([]byte("a"), 3000)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			n, err := th.Writer(context.Background(), &buf).Write(data)
+			if err != nil || n != len(data) {
+				t.Errorf("expect to write %d bytes, but got %d (%v)", len(data), n, err)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expect to take ~250ms, but took %s", elapsed)
+	}
+}
+
+func TestThrottleCancel(t *testing.T) {
+	t.Parallel()
+
+	th := unit.NewThrottle(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := io.Copy(th.Writer(ctx, ioutil.Discard), bytes.NewReader(make([]byte, 10)))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expect to get %s, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestThrottleUnlimited(t *testing.T) {
+	t.Parallel()
+
+	th := unit.NewThrottle(0, 0)
+	if err := th.WaitN(context.Background(), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	w := &countingWriter{}
+	n, err := th.Writer(context.Background(), w).Write(make([]byte, 100000))
+	if err != nil || n != 100000 {
+		t.Fatalf("expect to write 100000 bytes, but got %d (%v)", n, err)
+	}
+	if w.calls != 1 {
+		t.Errorf("expect a single write, but got %d", w.calls)
+	}
+
+	th.SetRate(unit.ByteRate(unit.MB))
+	if th.Rate() != unit.ByteRate(unit.MB) {
+		t.Errorf("expect to get rate %s, but got %s", unit.ByteRate(unit.MB), th.Rate())
+	}
+	if th.Burst() != unit.MB {
+		t.Errorf("expect a burst of %s, but got %s", unit.MB, th.Burst())
+	}
+	start := time.Now()
+	if err := th.WaitN(context.Background(), 100000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expect to start with a full bucket, but waited %s", elapsed)
+	}
+}
+
+// countingWriter counts the calls to Write
+type countingWriter struct {
+	calls int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	return len(p), nil
+}